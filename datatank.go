@@ -1,124 +1,641 @@
 package ktnuitygo
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-func saveJson[T any](filename string, data T) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create file '%s': %w", filename, err)
+type TankLoadError struct {
+	isSafe			bool
+	message			string
+	err				error
+}
+
+func (err *TankLoadError) Error() string {
+	return err.message
+}
+
+func (err *TankLoadError) Unwrap() error {
+	return err.err
+}
+
+var defaultTankStore = TankStoreNew(".")
+
+// Points the default store, used by DataTankNew, at dir.
+func DataTankSetDir(dir string) {
+	defaultTankStore = TankStoreNew(dir, defaultTankStore.options...)
+}
+
+type DataTankOption func(*dataTankConfig)
+
+type dataTankConfig struct {
+	fileLock   bool
+	codec      TankCodec
+	storage    TankStorage
+	migrations []TankMigrationFn
+	backups    *TankBackupPolicy
+	journal    *TankJournalPolicy
+	behind     *TankWriteBehindPolicy
+	keys       TankKeyProvider
+	plaintext  bool
+	integrity  *TankIntegrityPolicy
+	validators []tankValidatorFn
+	init       []InitOption
+	cacheSize  int
+
+	mutationCheck ErrorConsumerFn
+	history       *TankHistoryPolicy
+	seed          *tankSeedConfig
+	revisions     bool
+}
+
+// Guards DataTankSet and Save with an advisory lock on a sidecar 'name.tank.lock'
+// file. DataTankSet then reloads the latest file contents before applying the
+// mutation, giving read-modify-write across processes sharing a tank directory.
+// The storage must implement TankStorageLocker.
+func DataTankWithFileLock() DataTankOption {
+	return func(config *dataTankConfig) {
+		config.fileLock = true
 	}
-	defer file.Close()
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "    ")
+func DataTankWithStorage(storage TankStorage) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.storage = storage
+	}
+}
 
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("failed to encode JSON: %w", err)
+// Allocates nil struct pointers in exported fields whenever the tank data is
+// initialized, see InitWithPointers.
+func DataTankWithInitPointers() DataTankOption {
+	return func(config *dataTankConfig) {
+		config.init = append(config.init, InitWithPointers())
 	}
+}
 
-	return nil
+func DataTankNew[T any](name string, options ...DataTankOption) (*DataTank[T], error) {
+	return TankStoreOpen[T](defaultTankStore, name, options...)
 }
 
-type TankLoadError struct {
-	isSafe			bool
-	message			string
+type DataTankSetFn[T any] func(data *T)
+type DataTankGetFn[R any, T any] func(data *T) *R
+type DataTankUpdateFn[T any] func(data *T) error
+
+// DataTank is safe for concurrent use. Readers share mu, mutations and reloads
+// hold it exclusively, and saveMu keeps file writes in mutation order.
+type DataTank[T any] struct {
+	name   string
+	data   *T
+	config dataTankConfig
+
+	mu     sync.RWMutex
+	saveMu sync.Mutex
+
+	// Guarded by saveMu.
+	journal tankJournalState
+	// Fingerprint of our own last write, only tracked while watched.
+	watched     atomic.Bool
+	fingerprint [32]byte
+
+	// Held while dispatching changes, taken over from saveMu to keep them in order.
+	notifyMu    sync.Mutex
+	subscribers tankSubscribers[T]
+
+	behind tankWriteBehind
+
+	mutations tankMutationCheck[T]
+	history   tankHistory
+	// Guarded by saveMu.
+	revision TankRevision
 }
 
-func (err *TankLoadError) Error() string {
-	return err.message
+func tankFileName(name string, extension string) string {
+	return fmt.Sprintf("%s.tank.%s", name, extension)
 }
 
-func loadJson[T any](filename string, target *T) *TankLoadError {
-	file, err := os.Open(filename)
-	if err != nil {
-		return &TankLoadError{
+type tankLoadInfo struct {
+	// The data was changed while loading, e.g. migrated, and should be saved back.
+	dirty    bool
+	journal  tankJournalState
+	revision TankRevision
+	// The file was quarantined on reload, the current data stays and should be
+	// saved back, see keepData.
+	kept bool
+}
+
+// Always returns usable data, even alongside an error. reloading is set when
+// the tank already holds data, which is kept over defaults where possible.
+func (d *DataTank[T]) load(reloading bool) (data *T, info tankLoadInfo, err *TankLoadError) {
+	data = new(T)
+	defer func() {
+		// Also covers the error paths, where only a bad default tag is worth reporting.
+		if verifyErr := d.verify(data); verifyErr != nil && (err == nil || err.isSafe) {
+			err = d.initError(verifyErr)
+		}
+	}()
+
+	content, readErr := d.config.storage.Read(d.fileName())
+	info.revision = d.revisionOf(content, readErr)
+
+	seeded, seedErr := d.seed(data, errors.Is(readErr, fs.ErrNotExist))
+	if seedErr != nil {
+		return data, info, seedErr
+	}
+
+	info.dirty = seeded
+
+	if defaultsErr := d.defaults(data); defaultsErr != nil {
+		return data, info, d.initError(defaultsErr)
+	}
+
+	if readErr == nil {
+		dirty, decodeErr := d.decode(content, data)
+		if decodeErr != nil {
+			var recovered *T
+			recovered, decodeErr = d.recover(decodeErr, reloading)
+			if recovered != nil {
+				data = recovered
+			}
+
+			if recovered == nil && decodeErr == nil {
+				info.kept = true
+				return data, info, nil
+			}
+		}
+
+		if decodeErr != nil {
+			return data, info, &TankLoadError{
+				isSafe: false,
+				message: decodeErr.Error(),
+				err: decodeErr,
+			}
+		}
+
+		info.dirty = dirty
+	}
+
+	if d.config.journal != nil {
+		replayed, journal, journalErr := d.replayJournal(data)
+		if journalErr != nil {
+			return data, info, &TankLoadError{
+				isSafe: false,
+				message: journalErr.Error(),
+				err: journalErr,
+			}
+		}
+
+		info.journal = journal
+		info.dirty = info.dirty || journal.compact
+
+		if replayed != nil {
+			data, readErr = replayed, nil
+		}
+	}
+
+	if readErr != nil {
+		return data, info, &TankLoadError{
 			isSafe: true,
-			message: fmt.Sprintf("failed to open file '%s': %v", filename, err),
+			message: fmt.Sprintf("failed to open file '%s': %v", d.fileName(), readErr),
 		}
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	if verifyErr := d.verify(data); verifyErr != nil {
+		return data, info, d.initError(verifyErr)
+	}
 
-	if err := decoder.Decode(target); err != nil {
-		return &TankLoadError{
+	if validateErr := d.validate(data); validateErr != nil {
+		return data, info, &TankLoadError{
 			isSafe: false,
-			message: fmt.Sprintf("failed to decode JSON: %v", err),
+			message: fmt.Sprintf("failed to validate '%s': %v", d.fileName(), validateErr),
+			err: validateErr,
 		}
 	}
 
-	return nil
+	return data, info, nil
 }
 
-var tankDir string = "."
-func DataTankSetDir(dir string) {
-	for len(dir) > 0 && dir[len(dir)-1] == '/' {
-		dir = dir[:len(dir)-1]
+// Must be called on fresh data before decoding into it, see initBeforeDecode.
+func (d *DataTank[T]) defaults(data *T) error {
+	return verify(data, true, append(slices.Clone(d.config.init), initWithPhase(initBeforeDecode))...)
+}
+
+// Must be called after decoding, see initAfterDecode.
+func (d *DataTank[T]) verify(data *T) error {
+	return verify(data, true, append(slices.Clone(d.config.init), initWithPhase(initAfterDecode))...)
+}
+
+func (d *DataTank[T]) initError(err error) *TankLoadError {
+	return &TankLoadError{
+		isSafe: false,
+		message: fmt.Sprintf("failed to initialize '%s': %v", d.fileName(), err),
+		err: err,
 	}
+}
 
-	if len(dir) == 0 {
-		tankDir = "."
-		return
+// Must be called with mu held.
+func (d *DataTank[T]) setData(data *T, info tankLoadInfo) {
+	d.data = data
+	d.recordBaseline()
+
+	if d.config.journal != nil {
+		d.saveMu.Lock()
+		d.journal = info.journal
+		d.journal.compact = d.journal.compact || info.dirty
+		d.journal.base = nil
+		if doc, err := toJsonDoc(data); err == nil {
+			d.journal.base = doc
+		}
+		d.saveMu.Unlock()
 	}
 
-	tankDir = dir
+	if d.config.revisions {
+		d.saveMu.Lock()
+		d.revision = info.revision
+		d.saveMu.Unlock()
+	}
 }
 
-func DataTankNew[T any](name string) (*DataTank[T], error) {
-	var data T
+func (d *DataTank[T]) decode(content []byte, target *T) (bool, error) {
+	content, err := d.open(content)
+	if err != nil {
+		return false, err
+	}
 
-	err := loadJson(tankPath(name), &data)
-	if err != nil && !err.isSafe {
-		return nil, fmt.Errorf("failed to load DataTank '%s' data: %w", name, err)
+	content, err = d.verifyChecksum(content)
+	if err != nil {
+		return false, err
+	}
+
+	if d.config.migrations != nil {
+		return d.decodeSchema(content, target)
 	}
 
-	verify(&data, true)
+	if err := d.config.codec.Decode(content, target); err != nil {
+		return false, d.corruption(TankDecodeFailed, fmt.Errorf("failed to decode %s: %v", strings.ToUpper(d.config.codec.Extension()), err))
+	}
 
-	return &DataTank[T]{
-		name: name,
-		data: &data,
-	}, nil
+	return false, nil
 }
 
-type DataTankSetFn[T any] func(data *T)
-type DataTankGetFn[R any, T any] func(data *T) *R
+func (d *DataTank[T]) fileName() string {
+	return tankFileName(d.name, d.config.codec.Extension())
+}
 
-type DataTank[T any] struct {
-	name string
-	data *T
+func (d *DataTank[T]) encode(data *T) ([]byte, error) {
+	var value any = data
+	if d.config.migrations != nil {
+		value = tankEnvelope[T]{Schema: len(d.config.migrations), Data: data}
+	}
+
+	content, err := d.config.codec.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", strings.ToUpper(d.config.codec.Extension()), err)
+	}
+
+	return content, nil
 }
 
-func tankPath(name string) string {
-	return fmt.Sprintf("%s/%s.tank.json", tankDir, name)
+func (d *DataTank[T]) lockFile() (func(), error) {
+	if !d.config.fileLock {
+		return func() {}, nil
+	}
+
+	locker, ok := d.config.storage.(TankStorageLocker)
+	if !ok {
+		return nil, fmt.Errorf("failed to lock DataTank '%s': storage does not support locking", d.name)
+	}
+
+	unlock, err := locker.Lock(tankFileName(d.name, "lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock DataTank '%s': %w", d.name, err)
+	}
+
+	return unlock, nil
 }
 
 func (d *DataTank[T]) Save() error {
-	return saveJson(tankPath(d.name), d.data)
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.RLock()
+	d.checkMutations()
+
+	if err := d.validate(d.data); err != nil {
+		d.mu.RUnlock()
+		return err
+	}
+
+	return d.saveLocked(d.mu.RUnlock, nil, false)
 }
 
+// Must be called with mu held, unlock releases it. The data is encoded under mu,
+// which is then handed over to saveMu so the write itself doesn't block readers.
+// notify, if any, is dispatched once the data has been persisted. conditional
+// fails the save with a TankConflictError if the file changed since it was loaded.
+func (d *DataTank[T]) saveLocked(unlock func(), notify func(), conditional bool) error {
+	pending := d.behind.take()
+
+	content, err := d.prepare(d.data)
+	if err != nil {
+		d.behind.restore(pending)
+		unlock()
+		return err
+	}
+
+	d.saveMu.Lock()
+	unlock()
+
+	if err := d.persist(content, conditional); err != nil {
+		d.behind.restore(pending)
+		d.saveMu.Unlock()
+		return err
+	}
+
+	d.dispatchLocked(notify)
+	return nil
+}
+
+// Encodes data for persist, which differs from encode in journal mode.
+func (d *DataTank[T]) prepare(data *T) ([]byte, error) {
+	if d.config.journal != nil {
+		return encodeJournalDoc(data)
+	}
+
+	return d.encode(data)
+}
+
+// Must be called with saveMu held.
+func (d *DataTank[T]) persist(content []byte, conditional bool) error {
+	defer d.recordFingerprint()
+
+	if conditional && d.config.journal != nil {
+		return fmt.Errorf("failed to save DataTank '%s': conditional saves are not supported in journal mode", d.name)
+	}
+
+	var err error
+	if d.config.journal != nil {
+		err = d.appendJournal(content)
+	} else {
+		err = d.write(content, conditional)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Only once the change it records is saved.
+	d.writeHistory()
+	return nil
+}
+
+// Writes the full tank file. Must be called with saveMu held.
+func (d *DataTank[T]) write(content []byte, conditional bool) error {
+	revision, err := d.nextRevision(conditional)
+	if err != nil {
+		return err
+	}
+
+	content, err = d.seal(d.addHeader(content, revision))
+	if err != nil {
+		return err
+	}
+
+	if d.config.backups != nil {
+		if err := d.backup(); err != nil {
+			return fmt.Errorf("failed to back up DataTank '%s': %w", d.name, err)
+		}
+	}
+
+	if err := d.config.storage.Write(d.fileName(), content); err != nil {
+		return err
+	}
+
+	d.recordRevision(revision, content)
+	return nil
+}
+
+// Replaces the data with the file's contents. Mutations wait until it is done,
+// so none of them is overwritten by the older file.
 func (d *DataTank[T]) Reload() error {
-	var data T
+	d.mu.Lock()
+	d.checkMutations()
+
+	// Saves handed over to saveMu may still be writing, the file is only read
+	// once they landed.
+	d.saveMu.Lock()
+	d.saveMu.Unlock()
 
-	err := loadJson(tankPath(d.name), &data)
+	data, info, err := d.load(true)
 	if err != nil {
+		d.mu.Unlock()
 		return fmt.Errorf("failed to reload DataTank '%s' data: %w", d.name, err)
 	}
 
-	verify(&data, true)
+	if info.kept {
+		d.keepData()
+		d.mu.Unlock()
+		return d.Save()
+	}
+
+	previous := d.data
+	d.setData(data, info)
+	notify := d.changeNotifier(previous)
+	d.saveMu.Lock()
+	d.mu.Unlock()
+	d.dispatchLocked(notify)
+
+	if info.dirty {
+		return d.Save()
+	}
 
-	d.data = &data
 	return nil
 }
 
+// fn gets the live data, which must only be read. Use DataTankSnapshot for
+// anything that outlives fn.
 func DataTankGet[R any, T any](d *DataTank[T], fn DataTankGetFn[R, T]) *R {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return fn(d.data)
 }
 
 func DataTankSet[T any](d *DataTank[T], fn DataTankSetFn[T]) error {
+	return DataTankSetLabeled(d, "", fn)
+}
+
+// Like DataTankSet, label describes the change in the tank's history.
+func DataTankSetLabeled[T any](d *DataTank[T], label string, fn DataTankSetFn[T]) error {
+	// An invalid state must not stick, so fn has to run on a copy.
+	if d.validated() {
+		return DataTankUpdateLabeled(d, label, func(data *T) error {
+			fn(data)
+			return nil
+		})
+	}
+
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.Lock()
+	d.checkMutations()
+	previous := d.copyForChange()
+
+	if err := d.latestLocked(); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	before, err := d.historyState()
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
 	fn(d.data)
-	return d.Save()
+	d.recordBaseline()
+
+	// The change sticks in memory either way, and so does its history.
+	if _, err := d.pushHistory(before, d.data, label); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	if d.deferred() {
+		return d.deferSaveLocked(d.mu.Unlock, d.changeNotifier(previous))
+	}
+
+	return d.saveLocked(d.mu.Unlock, d.changeNotifier(previous), false)
+}
+
+// Runs fn on a deep copy of the data and only commits it to memory once it has
+// been saved. If fn returns an error or the save fails, the tank is unchanged.
+// With write-behind the copy is committed as soon as fn succeeds.
+func DataTankUpdate[T any](d *DataTank[T], fn DataTankUpdateFn[T]) error {
+	return DataTankUpdateLabeled(d, "", fn)
+}
+
+// Like DataTankUpdate, label describes the change in the tank's history.
+func DataTankUpdateLabeled[T any](d *DataTank[T], label string, fn DataTankUpdateFn[T]) error {
+	return dataTankUpdate(d, label, false, fn)
+}
+
+// conditional saves right away even with write-behind, failing with a
+// TankConflictError if the file changed since it was loaded.
+func dataTankUpdate[T any](d *DataTank[T], label string, conditional bool, fn DataTankUpdateFn[T]) error {
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.Lock()
+	d.checkMutations()
+	previous := d.copyForChange()
+
+	if err := d.latestLocked(); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	data := deepCopy(d.data)
+	if err := fn(data); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	if err := d.validate(data); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	before, err := d.historyState()
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	rollback, err := d.pushHistory(before, data, label)
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	if d.deferred() && !conditional {
+		d.data = data
+		d.recordBaseline()
+		return d.deferSaveLocked(d.mu.Unlock, d.changeNotifier(previous))
+	}
+
+	// Pending write-behind mutations are part of this save.
+	pending := d.behind.take()
+
+	content, err := d.prepare(data)
+	if err != nil {
+		d.behind.restore(pending)
+		rollback()
+		d.mu.Unlock()
+		return err
+	}
+
+	d.saveMu.Lock()
+
+	if err := d.persist(content, conditional); err != nil {
+		d.behind.restore(pending)
+		rollback()
+		d.saveMu.Unlock()
+		d.mu.Unlock()
+		return err
+	}
+
+	d.data = data
+	d.recordBaseline()
+	notify := d.changeNotifier(previous)
+	d.mu.Unlock()
+	d.dispatchLocked(notify)
+	return nil
+}
+
+// Must be called with mu held. Brings the data up to date with the file before
+// a mutation when file locking is enabled.
+func (d *DataTank[T]) latestLocked() error {
+	if !d.config.fileLock {
+		return nil
+	}
+
+	data, info, err := d.load(true)
+	if err != nil && !err.isSafe {
+		return fmt.Errorf("failed to reload DataTank '%s' data: %w", d.name, err)
+	}
+
+	if info.kept {
+		d.keepData()
+	} else if err == nil {
+		d.setData(data, info)
+	}
+
+	return nil
+}
+
+// Keeps the current data after its file was quarantined. The journal went along
+// with it, so the next save writes the full state.
+func (d *DataTank[T]) keepData() {
+	if d.config.journal == nil {
+		return
+	}
+
+	d.saveMu.Lock()
+	d.journal = tankJournalState{compact: true}
+	d.saveMu.Unlock()
 }
@@ -0,0 +1,226 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+type TankBackupPolicy struct {
+	// Number of snapshots to keep, 0 keeps all of them.
+	MaxCount int
+	// Snapshots older than this are removed, 0 keeps them forever.
+	MaxAge time.Duration
+	// Loads the newest snapshot that decodes when the tank file itself doesn't,
+	// same as TankCorruptionKeepLastGood.
+	Fallback bool
+}
+
+type TankSnapshot struct {
+	Name string
+	Time time.Time
+	Size int64
+}
+
+const tankBackupTimeFormat = "20060102T150405.000000000Z"
+
+// Keeps the previous tank file as 'name.tank.<ext>.<timestamp>.bak' before
+// every save, pruned according to policy.
+func DataTankWithBackups(policy TankBackupPolicy) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.backups = &policy
+	}
+}
+
+func (d *DataTank[T]) backupPrefix() string {
+	return d.fileName() + "."
+}
+
+// Must be called with saveMu held.
+func (d *DataTank[T]) backup() error {
+	previous, err := d.config.storage.Read(d.fileName())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	name := d.backupPrefix() + time.Now().UTC().Format(tankBackupTimeFormat) + ".bak"
+	if err := d.config.storage.Write(name, previous); err != nil {
+		return err
+	}
+
+	return d.pruneBackups()
+}
+
+func (d *DataTank[T]) pruneBackups() error {
+	snapshots, err := d.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	policy := d.config.backups
+	cutoff := time.Now().Add(-policy.MaxAge)
+
+	for i, snapshot := range snapshots {
+		tooMany := policy.MaxCount > 0 && i >= policy.MaxCount
+		tooOld := policy.MaxAge > 0 && snapshot.Time.Before(cutoff)
+
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := d.config.storage.Delete(snapshot.Name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lists the tank's snapshots, newest first.
+func (d *DataTank[T]) Snapshots() ([]TankSnapshot, error) {
+	files, err := d.config.storage.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of DataTank '%s': %w", d.name, err)
+	}
+
+	prefix := d.backupPrefix()
+	result := make([]TankSnapshot, 0, len(files))
+
+	for _, file := range files {
+		stamp, ok := strings.CutPrefix(file.Name, prefix)
+		if !ok {
+			continue
+		}
+
+		stamp, ok = strings.CutSuffix(stamp, ".bak")
+		if !ok {
+			continue
+		}
+
+		at, err := time.Parse(tankBackupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, TankSnapshot{
+			Name: file.Name,
+			Time: at,
+			Size: file.Size,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b TankSnapshot) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	return result, nil
+}
+
+// Replaces the tank with snapshot, which is saved through the regular path so
+// the current state is itself backed up first.
+func (d *DataTank[T]) Restore(snapshot TankSnapshot) error {
+	content, err := d.config.storage.Read(snapshot.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot '%s': %w", snapshot.Name, err)
+	}
+
+	data := new(T)
+	if err := d.defaults(data); err != nil {
+		return fmt.Errorf("failed to restore snapshot '%s': %w", snapshot.Name, err)
+	}
+
+	if _, err := d.decode(content, data); err != nil {
+		return fmt.Errorf("failed to restore snapshot '%s': %w", snapshot.Name, err)
+	}
+
+	if err := d.verify(data); err != nil {
+		return fmt.Errorf("failed to restore snapshot '%s': %w", snapshot.Name, err)
+	}
+
+	if err := d.validate(data); err != nil {
+		return fmt.Errorf("failed to restore snapshot '%s': %w", snapshot.Name, err)
+	}
+
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.Lock()
+	d.checkMutations()
+
+	before, err := d.historyState()
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	// Like any other change, a restore can be undone.
+	rollback, err := d.pushHistory(before, data, "restore")
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	// Pending write-behind mutations are superseded by this save.
+	pending := d.behind.take()
+
+	content, err = d.prepare(data)
+	if err != nil {
+		d.behind.restore(pending)
+		rollback()
+		d.mu.Unlock()
+		return err
+	}
+
+	d.saveMu.Lock()
+
+	if err := d.persist(content, false); err != nil {
+		d.behind.restore(pending)
+		rollback()
+		d.saveMu.Unlock()
+		d.mu.Unlock()
+		return err
+	}
+
+	previous := d.copyForChange()
+	d.data = data
+	d.recordBaseline()
+	notify := d.changeNotifier(previous)
+	d.mu.Unlock()
+	d.dispatchLocked(notify)
+	return nil
+}
+
+func (d *DataTank[T]) loadNewestBackup() *T {
+	snapshots, err := d.Snapshots()
+	if err != nil {
+		return nil
+	}
+
+	for _, snapshot := range snapshots {
+		content, err := d.config.storage.Read(snapshot.Name)
+		if err != nil {
+			continue
+		}
+
+		data := new(T)
+		if err := d.defaults(data); err != nil {
+			return nil
+		}
+
+		if _, err := d.decode(content, data); err == nil {
+			return data
+		}
+	}
+
+	return nil
+}
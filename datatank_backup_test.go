@@ -0,0 +1,157 @@
+package ktnuitygo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDataTankBackupRotation(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithBackups(TankBackupPolicy{MaxCount: 2}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-backup")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := range 4 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	snapshots, err := tank.Snapshots()
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", len(snapshots))
+	}
+
+	if !snapshots[0].Time.After(snapshots[1].Time) {
+		t.Errorf("Expected snapshots newest first, got %v", snapshots)
+	}
+
+	if err := tank.Restore(snapshots[1]); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	if tank.data.Count != 2 {
+		t.Errorf("Expected Count to be 2 after restore, got %d", tank.data.Count)
+	}
+
+	reloaded, err := TankStoreOpen[TestData](store, "test-backup")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 2 {
+		t.Errorf("Expected restored Count to be persisted, got %d", reloaded.data.Count)
+	}
+}
+
+func TestDataTankRestoreFailedSave(t *testing.T) {
+	storage := &failingTankStorage{TankStorage: TankStorageMemory()}
+	store := TankStoreWithStorage(storage, DataTankWithBackups(TankBackupPolicy{MaxCount: 2}), DataTankWithHistory(TankHistoryPolicy{}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-restore-failed")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	snapshots, err := tank.Snapshots()
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+
+	storage.fail = "test-restore-failed.tank.json"
+	if err := tank.Restore(snapshots[len(snapshots)-1]); err == nil {
+		t.Fatalf("Expected restore to fail")
+	}
+
+	if tank.data.Count != 2 {
+		t.Errorf("Expected a failed restore to keep Count 2, got %d", tank.data.Count)
+	}
+
+	if history := tank.History(); len(history.Undo) != 2 {
+		t.Errorf("Expected a failed restore not to be recorded, got %+v", history)
+	}
+}
+
+func TestDataTankBackupMaxAge(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithBackups(TankBackupPolicy{MaxAge: time.Hour}))
+
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(tankBackupTimeFormat)
+	storage.Write("test-age.tank.json."+old+".bak", []byte("{}"))
+	storage.Write("test-age.tank.json", []byte("{}"))
+
+	tank, err := TankStoreOpen[TestData](store, "test-age")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if err := tank.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	snapshots, err := tank.Snapshots()
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+
+	if len(snapshots) != 1 || snapshots[0].Time.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("Expected only the fresh snapshot to remain, got %v", snapshots)
+	}
+}
+
+func TestDataTankBackupFallback(t *testing.T) {
+	storage := TankStorageMemory()
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-fallback", DataTankWithBackups(TankBackupPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := range 2 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	storage.Write("test-fallback.tank.json", []byte("{corrupt"))
+
+	_, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-fallback", DataTankWithBackups(TankBackupPolicy{}))
+	if err == nil {
+		t.Fatal("Expected error without fallback")
+	}
+
+	recovered, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-fallback", DataTankWithBackups(TankBackupPolicy{Fallback: true}))
+	if err != nil {
+		t.Fatalf("Expected fallback to newest backup, got %v", err)
+	}
+
+	if recovered.data.Count != 1 {
+		t.Errorf("Expected Count from newest backup to be 1, got %d", recovered.data.Count)
+	}
+
+	if recovered.data.Meta == nil {
+		t.Error("Expected fallback data to be initialized")
+	}
+}
@@ -0,0 +1,124 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// TankCodec controls how a DataTank is serialized and which extension its file
+// uses, e.g. 'json' for 'name.tank.json'.
+type TankCodec interface {
+	Extension() string
+	Encode(value any) ([]byte, error)
+	Decode(data []byte, target any) error
+}
+
+var (
+	TankCodecJSON        TankCodec = jsonCodec{indent: "    "}
+	TankCodecCompactJSON TankCodec = jsonCodec{}
+	TankCodecGob         TankCodec = gobCodec{}
+	TankCodecYAML        TankCodec = yamlCodec{}
+	TankCodecTOML        TankCodec = tomlCodec{}
+)
+
+func DataTankWithCodec(codec TankCodec) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.codec = codec
+	}
+}
+
+type jsonCodec struct {
+	indent string
+}
+
+func (c jsonCodec) Extension() string {
+	return "json"
+}
+
+func (c jsonCodec) Encode(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", c.indent)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c jsonCodec) Decode(data []byte, target any) error {
+	return json.Unmarshal(data, target)
+}
+
+type gobCodec struct{}
+
+func (c gobCodec) Extension() string {
+	return "gob"
+}
+
+func (c gobCodec) Encode(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c gobCodec) Decode(data []byte, target any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(target)
+}
+
+type yamlCodec struct{}
+
+func (c yamlCodec) Extension() string {
+	return "yaml"
+}
+
+func (c yamlCodec) Encode(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c yamlCodec) Decode(data []byte, target any) error {
+	return yaml.Unmarshal(data, target)
+}
+
+type tomlCodec struct{}
+
+func (c tomlCodec) Extension() string {
+	return "toml"
+}
+
+func (c tomlCodec) Encode(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := toml.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c tomlCodec) Decode(data []byte, target any) error {
+	return toml.Unmarshal(data, target)
+}
@@ -0,0 +1,80 @@
+package ktnuitygo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDataTankCodecs(t *testing.T) {
+	codecs := map[string]TankCodec{
+		"json":         TankCodecJSON,
+		"compact-json": TankCodecCompactJSON,
+		"gob":          TankCodecGob,
+		"yaml":         TankCodecYAML,
+		"toml":         TankCodecTOML,
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			DataTankSetDir(tmpDir)
+
+			tank, err := DataTankNew[TestData]("test-codec", DataTankWithCodec(codec))
+			if err != nil {
+				t.Fatalf("Failed to create DataTank: %v", err)
+			}
+
+			err = DataTankSet(tank, func(data *TestData) {
+				data.Name = "Alice"
+				data.Count = 42
+				data.Items = []string{"foo", "bar"}
+				data.Meta = map[string]int{"x": 1}
+			})
+			if err != nil {
+				t.Fatalf("Failed to set DataTank data: %v", err)
+			}
+
+			path := tmpDir + "/test-codec.tank." + codec.Extension()
+			if _, err := os.Stat(path); err != nil {
+				t.Fatalf("Expected tank file '%s' to exist: %v", path, err)
+			}
+
+			reloaded, err := DataTankNew[TestData]("test-codec", DataTankWithCodec(codec))
+			if err != nil {
+				t.Fatalf("Failed to create DataTank: %v", err)
+			}
+
+			data := reloaded.data
+			if data.Name != "Alice" || data.Count != 42 || len(data.Items) != 2 || data.Meta["x"] != 1 {
+				t.Errorf("Expected data to round trip, got %+v", *data)
+			}
+		})
+	}
+}
+
+func TestTankCodecCompactJSON(t *testing.T) {
+	content, err := TankCodecCompactJSON.Encode(TestData{Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	if strings.Count(string(content), "\n") != 1 {
+		t.Errorf("Expected compact JSON on a single line, got '%s'", content)
+	}
+}
+
+func TestDataTankCodecDecodeError(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	err := os.WriteFile(tmpDir+"/bad.tank.yaml", []byte("name: [unclosed"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create bad file: %v", err)
+	}
+
+	_, err = DataTankNew[TestData]("bad", DataTankWithCodec(TankCodecYAML))
+	if err == nil || !strings.Contains(err.Error(), "failed to decode YAML") {
+		t.Errorf("Expected YAML decode error, got %v", err)
+	}
+}
@@ -0,0 +1,537 @@
+package ktnuitygo
+
+import (
+	"cmp"
+	"container/list"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const tankCollectionCacheSize = 128
+
+// Number of records a DataTankCollection keeps in memory, 0 uses the default of
+// 128. Ignored by plain tanks.
+func DataTankWithCacheSize(size int) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.cacheSize = size
+	}
+}
+
+// DataTankCollection keeps each record in its own DataTank, stored as a file
+// named after the key in the 'name.tank.d' directory. Records are loaded when
+// first accessed and the least recently used ones are dropped from memory once
+// there are more than the cache size. It is safe for concurrent use.
+type DataTankCollection[K cmp.Ordered, V any] struct {
+	name  string
+	store *TankStore
+	size  int
+
+	mu    sync.Mutex
+	cache map[K]*list.Element
+	order *list.List
+}
+
+type tankCollectionEntry[K cmp.Ordered, V any] struct {
+	key K
+	// Closed once the entry is opened, which happens outside the collection's mu.
+	// err is set if it couldn't be.
+	ready chan struct{}
+	err   error
+	tank  *DataTank[V]
+	// Held across Update and Delete, so they don't interleave on a record.
+	op sync.Mutex
+
+	// Guarded by the collection's mu.
+	// Unset while a new record hasn't been written yet.
+	exists bool
+	// Set once Delete took the record, users have to look it up again.
+	deleted bool
+	// Callers still using the entry, which keeps it from being evicted. Otherwise
+	// the key could be opened again as a second tank on the same file.
+	pins int
+	// Set while the entry is evicted, closed once its tank is. The key is looked
+	// up again after, so it isn't opened while the old tank still saves.
+	evicted chan struct{}
+}
+
+// Set on entries dropped before they were opened, those waiting on them look
+// the key up again.
+var errTankCollectionDropped = errors.New("collection entry dropped")
+
+func DataTankCollectionNew[K cmp.Ordered, V any](name string, options ...DataTankOption) (*DataTankCollection[K, V], error) {
+	return TankStoreCollection[K, V](defaultTankStore, name, options...)
+}
+
+// Opens the collection name, whose records use the store's options followed by
+// options. The store's storage must implement TankStorageSub.
+func TankStoreCollection[K cmp.Ordered, V any](store *TankStore, name string, options ...DataTankOption) (*DataTankCollection[K, V], error) {
+	if err := validateTankName(name); err != nil {
+		return nil, fmt.Errorf("failed to open DataTankCollection: %w", err)
+	}
+
+	parent, ok := store.storage.(TankStorageSub)
+	if !ok {
+		return nil, fmt.Errorf("failed to open DataTankCollection '%s': storage does not support directories", name)
+	}
+
+	storage, err := parent.Sub(tankFileName(name, "d"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DataTankCollection '%s': %w", name, err)
+	}
+
+	options = append(slices.Clone(store.options), options...)
+
+	config := dataTankConfig{}
+	for _, option := range options {
+		option(&config)
+	}
+
+	return &DataTankCollection[K, V]{
+		name:  name,
+		store: TankStoreWithStorage(storage, options...),
+		size:  cmp.Or(config.cacheSize, tankCollectionCacheSize),
+		cache: make(map[K]*list.Element),
+		order: list.New(),
+	}, nil
+}
+
+// Record files are named after the escaped key, so any key maps to a valid tank
+// name. Leading dots are escaped as well, since those are taken by temp files.
+func tankCollectionName[K cmp.Ordered](key K) (string, error) {
+	name := url.PathEscape(fmt.Sprint(key))
+	if name == "" {
+		return "", fmt.Errorf("%w: key is empty", ErrTankNameInvalid)
+	}
+
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+
+	return name, nil
+}
+
+func parseTankCollectionKey[K cmp.Ordered](name string) (K, error) {
+	var key K
+
+	text, err := url.PathUnescape(name)
+	if err != nil {
+		return key, err
+	}
+
+	value := reflect.ValueOf(&key).Elem()
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(text, 10, value.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		parsed, err := strconv.ParseUint(text, 10, value.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(text, value.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+
+		value.SetFloat(parsed)
+	}
+
+	return key, nil
+}
+
+// Returns the cached entry of key, opening its tank if create is set or its file
+// exists. Returns nil if the record doesn't exist and create isn't set. The
+// entry is pinned until passed to release.
+func (c *DataTankCollection[K, V]) entry(key K, create bool) (*tankCollectionEntry[K, V], error) {
+	name, err := tankCollectionName(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := c.lookup(key)
+	if entry != nil || err != nil {
+		return entry, err
+	}
+
+	// Still holding mu from lookup, the key is reserved before it is opened
+	// so that others wait for it instead of opening it as well.
+	entry = c.reserveLocked(key)
+	c.mu.Unlock()
+
+	// Records all share the collection's codec, so no other extension is looked for.
+	exists, err := c.store.exists(name, c.store.config().codec.Extension())
+
+	var tank *DataTank[V]
+	if err == nil && (exists || create) {
+		tank, err = TankStoreOpen[V](c.store, name)
+	}
+
+	if err != nil {
+		c.drop(entry, err)
+		return nil, err
+	}
+
+	if tank == nil {
+		c.drop(entry, errTankCollectionDropped)
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	entry.tank = tank
+	entry.exists = exists
+	evicted := c.trimLocked()
+	c.mu.Unlock()
+
+	close(entry.ready)
+	c.closeEvicted(evicted)
+	return entry, nil
+}
+
+// Returns the pinned entry of key, waiting for it to be opened or evicted
+// first. If key isn't cached, it returns nil with mu held.
+func (c *DataTankCollection[K, V]) lookup(key K) (*tankCollectionEntry[K, V], error) {
+	for {
+		c.mu.Lock()
+
+		element, ok := c.cache[key]
+		if !ok {
+			return nil, nil
+		}
+
+		entry := element.Value.(*tankCollectionEntry[K, V])
+		if evicted := entry.evicted; evicted != nil {
+			c.mu.Unlock()
+			<-evicted
+			continue
+		}
+
+		c.order.MoveToFront(element)
+		entry.pins++
+		c.mu.Unlock()
+
+		<-entry.ready
+		if entry.err == nil {
+			return entry, nil
+		}
+
+		c.release(entry)
+		if !errors.Is(entry.err, errTankCollectionDropped) {
+			return nil, entry.err
+		}
+	}
+}
+
+// Must be called with mu held. Caches a pinned entry for key that isn't ready
+// yet, to be opened or dropped by the caller.
+func (c *DataTankCollection[K, V]) reserveLocked(key K) *tankCollectionEntry[K, V] {
+	entry := &tankCollectionEntry[K, V]{key: key, ready: make(chan struct{}), pins: 1}
+	c.cache[key] = c.order.PushFront(entry)
+	return entry
+}
+
+// Removes a reserved entry that wasn't opened, handing err to those waiting on it.
+func (c *DataTankCollection[K, V]) drop(entry *tankCollectionEntry[K, V], err error) {
+	c.mu.Lock()
+	if element, ok := c.cache[entry.key]; ok && element.Value == entry {
+		c.order.Remove(element)
+		delete(c.cache, entry.key)
+	}
+
+	entry.pins--
+	entry.err = err
+	c.mu.Unlock()
+
+	close(entry.ready)
+}
+
+func (c *DataTankCollection[K, V]) release(entry *tankCollectionEntry[K, V]) {
+	c.mu.Lock()
+	entry.pins--
+	evicted := c.trimLocked()
+	c.mu.Unlock()
+
+	c.closeEvicted(evicted)
+}
+
+// Must be called with mu held. Takes the least recently used records that
+// aren't pinned out of the order until the cache fits its size again. They are
+// closed by closeEvicted once mu is released.
+func (c *DataTankCollection[K, V]) trimLocked() []*tankCollectionEntry[K, V] {
+	var evicted []*tankCollectionEntry[K, V]
+
+	for element := c.order.Back(); element != nil && c.order.Len() > c.size; {
+		previous := element.Prev()
+
+		entry := element.Value.(*tankCollectionEntry[K, V])
+		if entry.pins == 0 {
+			c.evictLocked(element)
+			evicted = append(evicted, entry)
+		}
+
+		element = previous
+	}
+
+	return evicted
+}
+
+// Must be called with mu held. The entry stays cached, so its key is waited
+// for until closeEvicted is done with it.
+func (c *DataTankCollection[K, V]) evictLocked(element *list.Element) {
+	entry := element.Value.(*tankCollectionEntry[K, V])
+	entry.evicted = make(chan struct{})
+	c.order.Remove(element)
+}
+
+// Closes evicted records, which saves mutations still pending with
+// write-behind. Records whose mutations can't be saved are cached again, so
+// they aren't lost. The next eviction or Close tries again.
+func (c *DataTankCollection[K, V]) closeEvicted(evicted []*tankCollectionEntry[K, V]) error {
+	var errs []error
+	for _, entry := range evicted {
+		err := entry.tank.Close()
+
+		c.mu.Lock()
+		if err != nil {
+			errs = append(errs, err)
+			c.cache[entry.key] = c.order.PushBack(entry)
+		} else {
+			delete(c.cache, entry.key)
+		}
+
+		done := entry.evicted
+		entry.evicted = nil
+		c.mu.Unlock()
+
+		close(done)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns a deep copy of the record of key, or false if there is none.
+func (c *DataTankCollection[K, V]) Get(key K) (V, bool, error) {
+	var zero V
+
+	entry, err := c.entry(key, false)
+	if err != nil {
+		return zero, false, fmt.Errorf("failed to get '%v' from DataTankCollection '%s': %w", key, c.name, err)
+	}
+
+	if entry == nil {
+		return zero, false, nil
+	}
+	defer c.release(entry)
+
+	c.mu.Lock()
+	exists := entry.exists && !entry.deleted
+	c.mu.Unlock()
+
+	if !exists {
+		return zero, false, nil
+	}
+
+	entry.tank.mu.RLock()
+	defer entry.tank.mu.RUnlock()
+
+	return *deepCopy(entry.tank.data), true, nil
+}
+
+// Replaces the record of key with value, creating it if needed.
+func (c *DataTankCollection[K, V]) Set(key K, value V) error {
+	return c.Update(key, func(data *V) error {
+		*data = *deepCopy(&value)
+		return nil
+	})
+}
+
+// Runs fn on the record of key, or a new zero record, with the semantics of
+// DataTankUpdate.
+func (c *DataTankCollection[K, V]) Update(key K, fn DataTankUpdateFn[V]) error {
+	for {
+		entry, err := c.entry(key, true)
+		if err != nil {
+			return fmt.Errorf("failed to set '%v' in DataTankCollection '%s': %w", key, c.name, err)
+		}
+
+		entry.op.Lock()
+
+		c.mu.Lock()
+		deleted := entry.deleted
+		c.mu.Unlock()
+
+		// Delete got in first and has dropped the entry by now, the record
+		// starts over from a new one.
+		if deleted {
+			entry.op.Unlock()
+			c.release(entry)
+			continue
+		}
+
+		err = DataTankUpdate(entry.tank, fn)
+		if err == nil {
+			c.mu.Lock()
+			entry.exists = true
+			c.mu.Unlock()
+		}
+
+		entry.op.Unlock()
+		c.release(entry)
+		return err
+	}
+}
+
+// Deletes the record of key. Returns an error wrapping fs.ErrNotExist if there
+// is none. Waits for updates of the record that are under way.
+func (c *DataTankCollection[K, V]) Delete(key K) error {
+	name, err := tankCollectionName(key)
+	if err != nil {
+		return err
+	}
+
+	entry, err := c.lookup(key)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		// The key is reserved, so it isn't opened while it's deleted.
+		entry = c.reserveLocked(key)
+		c.mu.Unlock()
+
+		err := c.deleteRecord(key, name)
+		c.drop(entry, errTankCollectionDropped)
+		return err
+	}
+
+	entry.op.Lock()
+	defer c.release(entry)
+	defer entry.op.Unlock()
+
+	c.mu.Lock()
+	entry.deleted = true
+	c.mu.Unlock()
+
+	// Pending mutations are saved, only to be deleted along with the rest.
+	entry.tank.Close()
+
+	// The entry stays cached until the files are gone, so updates wait for it
+	// instead of opening the old file.
+	err = c.deleteRecord(key, name)
+
+	c.mu.Lock()
+	if element, ok := c.cache[key]; ok && element.Value == entry {
+		c.order.Remove(element)
+		delete(c.cache, key)
+	}
+	c.mu.Unlock()
+
+	return err
+}
+
+func (c *DataTankCollection[K, V]) deleteRecord(key K, name string) error {
+	if err := c.store.Delete(name); err != nil {
+		return fmt.Errorf("failed to delete '%v' from DataTankCollection '%s': %w", key, c.name, err)
+	}
+
+	return nil
+}
+
+// Returns the keys of all records, sorted. Records only show up once saved, which
+// can take a while with write-behind.
+func (c *DataTankCollection[K, V]) Keys() ([]K, error) {
+	tanks, err := c.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DataTankCollection '%s': %w", c.name, err)
+	}
+
+	keys := make([]K, 0, len(tanks))
+	for _, info := range tanks {
+		key, err := parseTankCollectionKey[K](info.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key '%s' in DataTankCollection '%s': %w", info.Name, c.name, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Calls fn for each record in key order until it returns false. Records are
+// loaded through the cache, so a full pass only keeps the last ones in memory.
+func (c *DataTankCollection[K, V]) Range(fn func(key K, value V) bool) error {
+	keys, err := c.Keys()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		value, exists, err := c.Get(key)
+		if err != nil {
+			return err
+		}
+
+		// Deleted since listing.
+		if !exists {
+			continue
+		}
+
+		if !fn(key, value) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Saves pending write-behind mutations and drops all records from memory.
+// Records still in use are only saved, they are dropped once released.
+func (c *DataTankCollection[K, V]) Close() error {
+	var used []*DataTank[V]
+	var evicted []*tankCollectionEntry[K, V]
+
+	c.mu.Lock()
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+
+		entry := element.Value.(*tankCollectionEntry[K, V])
+		if entry.pins == 0 {
+			c.evictLocked(element)
+			evicted = append(evicted, entry)
+		} else if entry.tank != nil {
+			used = append(used, entry.tank)
+		}
+
+		element = next
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, tank := range used {
+		if err := tank.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := c.closeEvicted(evicted); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
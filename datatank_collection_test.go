@@ -0,0 +1,365 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type TestUser struct {
+	Name  string
+	Age   int
+	Roles []string
+}
+
+func TestDataTankCollection(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	users, err := DataTankCollectionNew[string, TestUser]("users")
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	if _, exists, err := users.Get("alice"); err != nil || exists {
+		t.Fatalf("Expected no record for alice, got %v, %v", exists, err)
+	}
+
+	for _, name := range []string{"bob", "alice", "../escape", ".hidden"} {
+		if err := users.Set(name, TestUser{Name: name, Age: len(name)}); err != nil {
+			t.Fatalf("Failed to set '%s': %v", name, err)
+		}
+	}
+
+	if _, err := os.Stat(tmpDir + "/users.tank.d/alice.tank.json"); err != nil {
+		t.Errorf("Expected record file for alice, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	for _, entry := range entries {
+		if entry.Name() != "users.tank.d" {
+			t.Errorf("Expected only the collection directory, got '%s'", entry.Name())
+		}
+	}
+
+	keys, err := users.Keys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+
+	expected := []string{"../escape", ".hidden", "alice", "bob"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+
+	reopened, err := DataTankCollectionNew[string, TestUser]("users")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTankCollection: %v", err)
+	}
+
+	user, exists, err := reopened.Get("../escape")
+	if err != nil || !exists || user.Age != 9 {
+		t.Errorf("Expected ../escape with Age 9, got %+v, %v, %v", user, exists, err)
+	}
+
+	if user.Roles == nil {
+		t.Error("Expected loaded record to be initialized")
+	}
+
+	if err := reopened.Delete("bob"); err != nil {
+		t.Fatalf("Failed to delete bob: %v", err)
+	}
+
+	if _, exists, _ := reopened.Get("bob"); exists {
+		t.Error("Expected bob to be gone")
+	}
+
+	if err := reopened.Delete("bob"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist deleting bob twice, got %v", err)
+	}
+
+	visited := 0
+	err = reopened.Range(func(key string, value TestUser) bool {
+		if key != value.Name {
+			t.Errorf("Expected value of '%s' to match, got %+v", key, value)
+		}
+
+		visited++
+		return visited < 2
+	})
+	if err != nil || visited != 2 {
+		t.Errorf("Expected Range to stop after 2 records, got %d, %v", visited, err)
+	}
+}
+
+func TestDataTankCollectionCache(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	numbers, err := TankStoreCollection[int, TestData](store, "numbers", DataTankWithCacheSize(2))
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	for i := range 5 {
+		if err := numbers.Set(i, TestData{Count: i}); err != nil {
+			t.Fatalf("Failed to set %d: %v", i, err)
+		}
+	}
+
+	if numbers.order.Len() != 2 || len(numbers.cache) != 2 {
+		t.Errorf("Expected 2 cached records, got %d", numbers.order.Len())
+	}
+
+	for i := range 5 {
+		value, exists, err := numbers.Get(i)
+		if err != nil || !exists || value.Count != i {
+			t.Errorf("Expected record %d, got %+v, %v, %v", i, value, exists, err)
+		}
+	}
+
+	keys, _ := numbers.Keys()
+	if fmt.Sprint(keys) != "[0 1 2 3 4]" {
+		t.Errorf("Expected keys [0 1 2 3 4], got %v", keys)
+	}
+
+	value, _, _ := numbers.Get(3)
+	value.Items = append(value.Items, "changed")
+	if again, _, _ := numbers.Get(3); len(again.Items) != 0 {
+		t.Error("Expected Get to return a copy")
+	}
+}
+
+func TestDataTankCollectionFailedCreate(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	items, err := TankStoreCollection[string, TestData](store, "items")
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	errFailed := errors.New("failed")
+	err = items.Update("x", func(data *TestData) error {
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("Expected update error, got %v", err)
+	}
+
+	if _, exists, _ := items.Get("x"); exists {
+		t.Error("Expected failed create not to add a record")
+	}
+}
+
+func TestDataTankCollectionWriteBehind(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	items, err := TankStoreCollection[string, TestData](store, "items",
+		DataTankWithCacheSize(1),
+		DataTankWithWriteBehind(TankWriteBehindPolicy{Quiet: time.Hour}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := items.Set(key, TestData{Name: key}); err != nil {
+				t.Errorf("Failed to set '%s': %v", key, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := items.Close(); err != nil {
+		t.Fatalf("Failed to close DataTankCollection: %v", err)
+	}
+
+	keys, _ := items.Keys()
+	if fmt.Sprint(keys) != "[a b]" {
+		t.Errorf("Expected evicted and closed records to be saved, got %v", keys)
+	}
+}
+
+func (s *slowTankStorage) Sub(dir string) (TankStorage, error) {
+	sub, err := s.TankStorage.(TankStorageSub).Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &slowTankStorage{TankStorage: sub, delay: s.delay}, nil
+}
+
+func TestDataTankCollectionConcurrent(t *testing.T) {
+	// Slow writes keep updates in flight while other keys evict their record.
+	store := TankStoreWithStorage(&slowTankStorage{TankStorage: TankStorageMemory(), delay: time.Millisecond})
+
+	counters, err := TankStoreCollection[string, TestData](store, "counters", DataTankWithCacheSize(1))
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b"} {
+		for range 200 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := counters.Update(key, func(data *TestData) error {
+					data.Count++
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Failed to update '%s': %v", key, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, key := range []string{"a", "b"} {
+		value, exists, err := counters.Get(key)
+		if err != nil || !exists || value.Count != 200 {
+			t.Errorf("Expected '%s' to be counted to 200, got %d, %v, %v", key, value.Count, exists, err)
+		}
+	}
+
+	if counters.order.Len() > 1 {
+		t.Errorf("Expected the cache to shrink back to 1 record, got %d", counters.order.Len())
+	}
+}
+
+func TestDataTankCollectionDeleteDuringUpdate(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	items, err := TankStoreCollection[string, TestData](store, "items")
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	if err := items.Set("x", TestData{Count: 1}); err != nil {
+		t.Fatalf("Failed to set 'x': %v", err)
+	}
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- items.Update("x", func(data *TestData) error {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			data.Count++
+			return nil
+		})
+	}()
+
+	<-started
+	if err := items.Delete("x"); err != nil {
+		t.Fatalf("Failed to delete 'x': %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to update 'x': %v", err)
+	}
+
+	if _, exists, _ := items.Get("x"); exists {
+		t.Error("Expected the delete after the update to win")
+	}
+
+	keys, _ := items.Keys()
+	if len(keys) != 0 {
+		t.Errorf("Expected no records on disk, got %v", keys)
+	}
+}
+
+// Blocks the first read of name until release is closed, signalling started.
+type gatedTankStorage struct {
+	TankStorage
+	gate *tankStorageGate
+}
+
+type tankStorageGate struct {
+	name    string
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *gatedTankStorage) Read(name string) ([]byte, error) {
+	if gate := s.gate; gate.release != nil && name == gate.name {
+		gate.once.Do(func() {
+			close(gate.started)
+			<-gate.release
+		})
+	}
+
+	return s.TankStorage.Read(name)
+}
+
+func (s *gatedTankStorage) Sub(dir string) (TankStorage, error) {
+	sub, err := s.TankStorage.(TankStorageSub).Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gatedTankStorage{TankStorage: sub, gate: s.gate}, nil
+}
+
+func TestDataTankCollectionSlowOpen(t *testing.T) {
+	gate := &tankStorageGate{name: "b.tank.json"}
+	store := TankStoreWithStorage(&gatedTankStorage{TankStorage: TankStorageMemory(), gate: gate})
+
+	records, err := TankStoreCollection[string, TestData](store, "records")
+	if err != nil {
+		t.Fatalf("Failed to create DataTankCollection: %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if err := records.Set(key, TestData{Name: key}); err != nil {
+			t.Fatalf("Failed to set '%s': %v", key, err)
+		}
+	}
+
+	if err := records.Close(); err != nil {
+		t.Fatalf("Failed to close DataTankCollection: %v", err)
+	}
+
+	if _, _, err := records.Get("a"); err != nil {
+		t.Fatalf("Failed to get 'a': %v", err)
+	}
+
+	gate.started = make(chan struct{})
+	gate.release = make(chan struct{})
+
+	loaded := make(chan TestData, 1)
+	go func() {
+		value, _, _ := records.Get("b")
+		loaded <- value
+	}()
+	<-gate.started
+
+	// Loading 'b' must not hold up other keys.
+	done := make(chan struct{})
+	go func() {
+		records.Get("a")
+		records.Keys()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected other keys to be usable while a record loads")
+	}
+
+	close(gate.release)
+	<-done
+
+	if value := <-loaded; value.Name != "b" {
+		t.Errorf("Expected 'b' to load once released, got %+v", value)
+	}
+}
@@ -0,0 +1,243 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// TankKeyProvider supplies AES keys (16, 24 or 32 bytes) for encrypted tanks.
+// Every file records the id of the key it was sealed with, so old keys stay
+// usable for loading while saves move over to the current one.
+type TankKeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+var (
+	// The file was sealed with a key the provider doesn't know about.
+	ErrTankKeyNotFound = errors.New("tank encryption key not found")
+	// The key didn't open the file, it is either the wrong key or the file was tampered with.
+	ErrTankWrongKey = errors.New("tank encryption key does not match")
+	// The file is encrypted but the tank has no encryption configured.
+	ErrTankEncrypted = errors.New("tank is encrypted")
+	// The file is plaintext but the tank is encrypted, see DataTankWithPlaintextMigration.
+	ErrTankNotEncrypted = errors.New("tank is not encrypted")
+)
+
+// Sealed files start with this, followed by the key id length, key id, nonce
+// and the AES-GCM ciphertext.
+var tankSealMagic = []byte("KTNK\x01")
+
+// Encrypts the tank file and journal with AES-GCM. Plaintext files and journal
+// records are rejected with ErrTankNotEncrypted, unless
+// DataTankWithPlaintextMigration is given as well.
+func DataTankWithEncryption(keys TankKeyProvider) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.keys = keys
+	}
+}
+
+// Lets an encrypted tank load plaintext files and journal records, which get
+// encrypted on the next save. Meant for moving existing tanks over to
+// encryption, anyone able to write the files can slip plaintext in while it is
+// given.
+func DataTankWithPlaintextMigration() DataTankOption {
+	return func(config *dataTankConfig) {
+		config.plaintext = true
+	}
+}
+
+type staticTankKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// Provides keys from a fixed set, saving with the key named current.
+func TankKeys(current string, keys map[string][]byte) TankKeyProvider {
+	return &staticTankKeys{
+		current: current,
+		keys:    keys,
+	}
+}
+
+func (k *staticTankKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.current)
+	return k.current, key, err
+}
+
+func (k *staticTankKeys) Key(id string) ([]byte, error) {
+	key, exists := k.keys[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrTankKeyNotFound, id)
+	}
+
+	return key, nil
+}
+
+// Reads keys from env, hex or base64 encoded, using the env key names as ids.
+// The first name is the current key, the rest are older ones kept for loading.
+func TankKeysFromEnv(env *EnvData, names ...string) (TankKeyProvider, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no tank key names given")
+	}
+
+	keys := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := env.GetString(name)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := decodeTankKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tank key '%s': %w", name, err)
+		}
+
+		keys[name] = key
+	}
+
+	return TankKeys(names[0], keys), nil
+}
+
+func decodeTankKey(value string) ([]byte, error) {
+	if key, err := hex.DecodeString(value); err == nil && validTankKeySize(len(key)) {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && validTankKeySize(len(key)) {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("expected a hex or base64 encoded 16, 24 or 32 byte key")
+}
+
+func validTankKeySize(size int) bool {
+	return size == 16 || size == 24 || size == 32
+}
+
+func tankAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (d *DataTank[T]) seal(content []byte) ([]byte, error) {
+	if d.config.keys == nil {
+		return content, nil
+	}
+
+	id, key, err := d.config.keys.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tank encryption key: %w", err)
+	}
+
+	if len(id) > 255 {
+		return nil, fmt.Errorf("tank encryption key id '%s' is too long", id)
+	}
+
+	aead, err := tankAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tank encryption key '%s': %w", id, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := make([]byte, 0, len(tankSealMagic)+1+len(id)+len(nonce))
+	header = append(header, tankSealMagic...)
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+	header = append(header, nonce...)
+
+	return aead.Seal(header, nonce, content, header[:len(header)-len(nonce)]), nil
+}
+
+func (d *DataTank[T]) open(content []byte) ([]byte, error) {
+	if !bytes.HasPrefix(content, tankSealMagic) {
+		return d.openPlain(content)
+	}
+
+	if d.config.keys == nil {
+		return nil, fmt.Errorf("failed to decrypt DataTank '%s': %w", d.name, ErrTankEncrypted)
+	}
+
+	rest := content[len(tankSealMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, fmt.Errorf("failed to decrypt DataTank '%s': truncated header", d.name)
+	}
+
+	id := string(rest[1 : 1+rest[0]])
+	headerSize := len(tankSealMagic) + 1 + len(id)
+
+	key, err := d.config.keys.Key(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DataTank '%s': %w", d.name, err)
+	}
+
+	aead, err := tankAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tank encryption key '%s': %w", id, err)
+	}
+
+	if len(content) < headerSize+aead.NonceSize() {
+		return nil, fmt.Errorf("failed to decrypt DataTank '%s': truncated header", d.name)
+	}
+
+	nonce := content[headerSize : headerSize+aead.NonceSize()]
+	ciphertext := content[headerSize+aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, content[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DataTank '%s' with key '%s': %w", d.name, id, ErrTankWrongKey)
+	}
+
+	return plaintext, nil
+}
+
+// Plaintext is only accepted without encryption or while migrating to it.
+func (d *DataTank[T]) openPlain(content []byte) ([]byte, error) {
+	if d.config.keys != nil && !d.config.plaintext {
+		return nil, fmt.Errorf("failed to decrypt DataTank '%s': %w", d.name, ErrTankNotEncrypted)
+	}
+
+	return content, nil
+}
+
+// Journal records are line based, so sealed ones are base64 encoded.
+func (d *DataTank[T]) sealRecord(content []byte) ([]byte, error) {
+	if d.config.keys == nil {
+		return content, nil
+	}
+
+	sealed, err := d.seal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.AppendEncode(nil, sealed), nil
+}
+
+func (d *DataTank[T]) openRecord(content []byte) ([]byte, error) {
+	// Plain records are JSON objects, sealed ones never start with a brace.
+	if len(content) > 0 && content[0] == '{' {
+		return d.openPlain(content)
+	}
+
+	sealed, err := base64.StdEncoding.AppendDecode(nil, content)
+	if err != nil || !bytes.HasPrefix(sealed, tankSealMagic) {
+		return d.openPlain(content)
+	}
+
+	return d.open(sealed)
+}
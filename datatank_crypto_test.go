@@ -0,0 +1,168 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	testTankKeyOld = bytes.Repeat([]byte{1}, 32)
+	testTankKeyNew = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestDataTankEncryption(t *testing.T) {
+	storage := TankStorageMemory()
+	keys := TankKeys("old", map[string][]byte{"old": testTankKeyOld})
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-crypto", DataTankWithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Name = "secret-token"
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	content, _ := storage.Read("test-crypto.tank.json")
+	if strings.Contains(string(content), "secret-token") {
+		t.Error("Expected tank file to be encrypted")
+	}
+
+	reloaded, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-crypto", DataTankWithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Name != "secret-token" {
+		t.Errorf("Expected Name to be decrypted, got '%s'", reloaded.data.Name)
+	}
+
+	wrong := TankKeys("old", map[string][]byte{"old": testTankKeyNew})
+	_, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-crypto", DataTankWithEncryption(wrong))
+	if !errors.Is(err, ErrTankWrongKey) {
+		t.Errorf("Expected ErrTankWrongKey, got %v", err)
+	}
+
+	_, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-crypto")
+	if !errors.Is(err, ErrTankEncrypted) {
+		t.Errorf("Expected ErrTankEncrypted, got %v", err)
+	}
+
+	missing := TankKeys("new", map[string][]byte{"new": testTankKeyNew})
+	_, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-crypto", DataTankWithEncryption(missing))
+	if !errors.Is(err, ErrTankKeyNotFound) {
+		t.Errorf("Expected ErrTankKeyNotFound, got %v", err)
+	}
+}
+
+func TestDataTankEncryptionRotation(t *testing.T) {
+	storage := TankStorageMemory()
+	storage.Write("test-rotate.tank.json", []byte(`{"Name": "plain"}`))
+
+	old := TankKeys("old", map[string][]byte{"old": testTankKeyOld})
+	_, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-rotate", DataTankWithEncryption(old))
+	if !errors.Is(err, ErrTankNotEncrypted) {
+		t.Fatalf("Expected ErrTankNotEncrypted without migration, got %v", err)
+	}
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-rotate", DataTankWithEncryption(old), DataTankWithPlaintextMigration())
+	if err != nil {
+		t.Fatalf("Expected plaintext tank to load while migrating, got %v", err)
+	}
+
+	if err := tank.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	rotated := TankKeys("new", map[string][]byte{"old": testTankKeyOld, "new": testTankKeyNew})
+	tank, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-rotate", DataTankWithEncryption(rotated))
+	if err != nil {
+		t.Fatalf("Expected old key to still load, got %v", err)
+	}
+
+	if err := tank.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	onlyNew := TankKeys("new", map[string][]byte{"new": testTankKeyNew})
+	tank, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-rotate", DataTankWithEncryption(onlyNew))
+	if err != nil {
+		t.Fatalf("Expected tank to be re-encrypted with the new key, got %v", err)
+	}
+
+	if tank.data.Name != "plain" {
+		t.Errorf("Expected Name to survive rotation, got '%s'", tank.data.Name)
+	}
+}
+
+func TestDataTankEncryptionJournal(t *testing.T) {
+	storage := TankStorageMemory()
+	keys := TankKeys("old", map[string][]byte{"old": testTankKeyOld})
+	store := TankStoreWithStorage(storage, DataTankWithEncryption(keys), DataTankWithJournal(TankJournalPolicy{}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-crypto-journal")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Name = "secret-token"
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	journal, _ := storage.Read("test-crypto-journal.tank.json.journal")
+	if len(journal) == 0 || strings.Contains(string(journal), "secret-token") {
+		t.Errorf("Expected encrypted journal, got '%s'", journal)
+	}
+
+	reloaded, err := TankStoreOpen[TestData](store, "test-crypto-journal")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Name != "secret-token" {
+		t.Errorf("Expected journal to be decrypted, got '%s'", reloaded.data.Name)
+	}
+
+	// Plaintext records slipped into the journal are rejected.
+	storage.Write("test-crypto-journal.tank.json.journal", append(journal, encodeJournalRecord([]byte(`{"Name":"forged"}`))...))
+
+	if _, err := TankStoreOpen[TestData](store, "test-crypto-journal"); !errors.Is(err, ErrTankNotEncrypted) {
+		t.Errorf("Expected ErrTankNotEncrypted for a plaintext record, got %v", err)
+	}
+}
+
+func TestTankKeysFromEnv(t *testing.T) {
+	env := &EnvData{config: map[string]string{
+		"TANK_KEY":     hex.EncodeToString(testTankKeyNew),
+		"TANK_KEY_OLD": "AQEBAQEBAQEBAQEBAQEBAQ==",
+		"TANK_KEY_BAD": "short",
+	}}
+
+	keys, err := TankKeysFromEnv(env, "TANK_KEY", "TANK_KEY_OLD")
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+
+	id, key, err := keys.CurrentKey()
+	if err != nil || id != "TANK_KEY" || !bytes.Equal(key, testTankKeyNew) {
+		t.Errorf("Expected current key TANK_KEY, got '%s' (%v)", id, err)
+	}
+
+	old, err := keys.Key("TANK_KEY_OLD")
+	if err != nil || len(old) != 16 {
+		t.Errorf("Expected 16 byte old key, got %d bytes (%v)", len(old), err)
+	}
+
+	if _, err := TankKeysFromEnv(env, "TANK_KEY_BAD"); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+}
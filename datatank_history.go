@@ -0,0 +1,353 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+)
+
+type TankHistoryPolicy struct {
+	// Number of changes that can be undone, 0 uses the default of 50.
+	MaxEntries int
+	// Receives errors writing the history file. The save itself has succeeded
+	// by then, the history is written again with the next one.
+	OnError ErrorConsumerFn
+}
+
+const tankHistoryDefaultEntries = 50
+
+var ErrTankHistoryEmpty = errors.New("nothing to undo or redo")
+
+// Keeps the state before each DataTankSet and DataTankUpdate, so changes can be
+// taken back with Undo and Redo. The history is saved to 'name.tank.<ext>.history'
+// along with the tank and loaded when it's opened. Changes that leave the data
+// as it was aren't recorded.
+//
+// States are kept through encoding/json, so T has to round trip through it
+// regardless of the codec.
+func DataTankWithHistory(policy TankHistoryPolicy) DataTankOption {
+	return func(config *dataTankConfig) {
+		if policy.MaxEntries <= 0 {
+			policy.MaxEntries = tankHistoryDefaultEntries
+		}
+
+		config.history = &policy
+	}
+}
+
+type TankHistoryEntry struct {
+	Time  time.Time
+	Label string
+}
+
+type TankHistory struct {
+	// Oldest first, Undo takes back the last one.
+	Undo []TankHistoryEntry
+	// Oldest first, Redo reapplies the last one.
+	Redo []TankHistoryEntry
+}
+
+type tankHistoryRecord struct {
+	Time  time.Time       `json:"time"`
+	Label string          `json:"label,omitempty"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type tankHistoryFile struct {
+	Undo []tankHistoryRecord `json:"undo"`
+	Redo []tankHistoryRecord `json:"redo"`
+}
+
+type tankHistory struct {
+	mu    sync.Mutex
+	file  tankHistoryFile
+	dirty bool
+}
+
+func (d *DataTank[T]) historyName() string {
+	return d.fileName() + ".history"
+}
+
+// Must be called with mu held. Returns the state to pass to pushHistory once
+// the data has changed, or nil without history.
+func (d *DataTank[T]) historyState() ([]byte, error) {
+	if d.config.history == nil {
+		return nil, nil
+	}
+
+	content, err := json.Marshal(d.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record history of DataTank '%s': %w", d.name, err)
+	}
+
+	return content, nil
+}
+
+// Must be called with mu held. Records before as the state prior to after and
+// clears the redo stack. The returned function takes the record back.
+func (d *DataTank[T]) pushHistory(before []byte, after *T, label string) (func(), error) {
+	if before == nil {
+		return func() {}, nil
+	}
+
+	content, err := json.Marshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record history of DataTank '%s': %w", d.name, err)
+	}
+
+	if bytes.Equal(before, content) {
+		return func() {}, nil
+	}
+
+	h := &d.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous, dirty := h.file, h.dirty
+
+	undo := append(slices.Clone(h.file.Undo), tankHistoryRecord{
+		Time:  time.Now(),
+		Label: label,
+		Data:  before,
+	})
+
+	if excess := len(undo) - d.config.history.MaxEntries; excess > 0 {
+		undo = undo[excess:]
+	}
+
+	h.file = tankHistoryFile{Undo: undo}
+	h.dirty = true
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.file, h.dirty = previous, dirty
+	}, nil
+}
+
+// Writes the history if it changed, after the tank was saved. Must be called
+// with saveMu held. Failures go to OnError and leave the history dirty.
+func (d *DataTank[T]) writeHistory() {
+	if d.config.history == nil {
+		return
+	}
+
+	d.history.mu.Lock()
+	defer d.history.mu.Unlock()
+
+	if !d.history.dirty {
+		return
+	}
+
+	if err := d.storeHistory(); err != nil {
+		if d.config.history.OnError != nil {
+			d.config.history.OnError(err)
+		}
+
+		return
+	}
+
+	d.history.dirty = false
+}
+
+// Must be called with history.mu held.
+func (d *DataTank[T]) storeHistory() error {
+	content, err := json.Marshal(d.history.file)
+	if err != nil {
+		return fmt.Errorf("failed to encode history of DataTank '%s': %w", d.name, err)
+	}
+
+	content, err = d.seal(content)
+	if err != nil {
+		return err
+	}
+
+	if err := d.config.storage.Write(d.historyName(), content); err != nil {
+		return fmt.Errorf("failed to write history of DataTank '%s': %w", d.name, err)
+	}
+
+	return nil
+}
+
+func (d *DataTank[T]) loadHistory() error {
+	if d.config.history == nil {
+		return nil
+	}
+
+	content, err := d.config.storage.Read(d.historyName())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read history of DataTank '%s': %w", d.name, err)
+	}
+
+	content, err = d.open(content)
+	if err != nil {
+		return fmt.Errorf("failed to read history of DataTank '%s': %w", d.name, err)
+	}
+
+	var file tankHistoryFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("failed to decode history of DataTank '%s': %w", d.name, err)
+	}
+
+	d.history.mu.Lock()
+	defer d.history.mu.Unlock()
+
+	d.history.file = file
+	return nil
+}
+
+func (d *DataTank[T]) History() TankHistory {
+	d.history.mu.Lock()
+	defer d.history.mu.Unlock()
+
+	entries := func(records []tankHistoryRecord) []TankHistoryEntry {
+		result := make([]TankHistoryEntry, 0, len(records))
+		for _, record := range records {
+			result = append(result, TankHistoryEntry{Time: record.Time, Label: record.Label})
+		}
+
+		return result
+	}
+
+	return TankHistory{
+		Undo: entries(d.history.file.Undo),
+		Redo: entries(d.history.file.Redo),
+	}
+}
+
+// Restores the state before the last change and saves it. Returns
+// ErrTankHistoryEmpty if there is nothing to undo.
+func (d *DataTank[T]) Undo() error {
+	return d.travel(true)
+}
+
+// Reapplies the last undone change and saves it. Returns ErrTankHistoryEmpty if
+// there is nothing to redo.
+func (d *DataTank[T]) Redo() error {
+	return d.travel(false)
+}
+
+// Moves the last record of one stack to the other, swapping its state with the
+// current one. Like DataTankUpdate, the tank is unchanged if the save fails.
+func (d *DataTank[T]) travel(undo bool) error {
+	action := "redo"
+	if undo {
+		action = "undo"
+	}
+
+	if d.config.history == nil {
+		return fmt.Errorf("failed to %s DataTank '%s': history is not enabled", action, d.name)
+	}
+
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.Lock()
+	d.checkMutations()
+
+	if err := d.latestLocked(); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	current, err := d.historyState()
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+
+	h := &d.history
+	h.mu.Lock()
+	previous, dirty := h.file, h.dirty
+
+	from, to := h.file.Redo, h.file.Undo
+	if undo {
+		from, to = to, from
+	}
+
+	if len(from) == 0 {
+		h.mu.Unlock()
+		d.mu.Unlock()
+		return fmt.Errorf("failed to %s DataTank '%s': %w", action, d.name, ErrTankHistoryEmpty)
+	}
+
+	record := from[len(from)-1]
+	from = slices.Clone(from[:len(from)-1])
+	to = append(slices.Clone(to), tankHistoryRecord{Time: record.Time, Label: record.Label, Data: current})
+
+	if undo {
+		h.file = tankHistoryFile{Undo: from, Redo: to}
+	} else {
+		h.file = tankHistoryFile{Undo: to, Redo: from}
+	}
+
+	h.dirty = true
+	h.mu.Unlock()
+
+	rollback := func() {
+		h.mu.Lock()
+		h.file, h.dirty = previous, dirty
+		h.mu.Unlock()
+	}
+
+	data := new(T)
+	if err := json.Unmarshal(record.Data, data); err != nil {
+		rollback()
+		d.mu.Unlock()
+		return fmt.Errorf("failed to %s DataTank '%s': %w", action, d.name, err)
+	}
+
+	if err := d.verify(data); err != nil {
+		rollback()
+		d.mu.Unlock()
+		return fmt.Errorf("failed to %s DataTank '%s': %w", action, d.name, err)
+	}
+
+	if err := d.validate(data); err != nil {
+		rollback()
+		d.mu.Unlock()
+		return fmt.Errorf("failed to %s DataTank '%s': %w", action, d.name, err)
+	}
+
+	// Pending write-behind mutations are superseded by this save.
+	pending := d.behind.take()
+
+	content, err := d.prepare(data)
+	if err != nil {
+		d.behind.restore(pending)
+		rollback()
+		d.mu.Unlock()
+		return err
+	}
+
+	d.saveMu.Lock()
+
+	if err := d.persist(content, false); err != nil {
+		d.behind.restore(pending)
+		rollback()
+		d.saveMu.Unlock()
+		d.mu.Unlock()
+		return err
+	}
+
+	previousData := d.copyForChange()
+	d.data = data
+	d.recordBaseline()
+	notify := d.changeNotifier(previousData)
+	d.mu.Unlock()
+	d.dispatchLocked(notify)
+	return nil
+}
@@ -0,0 +1,258 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDataTankHistory(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithHistory(TankHistoryPolicy{MaxEntries: 3}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-history")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if err := tank.Undo(); !errors.Is(err, ErrTankHistoryEmpty) {
+		t.Errorf("Expected ErrTankHistoryEmpty on a new tank, got %v", err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		err = DataTankSetLabeled(tank, "set", func(data *TestData) {
+			data.Count = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	err = DataTankUpdateLabeled(tank, "rename", func(data *TestData) error {
+		data.Name = "renamed"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update DataTank data: %v", err)
+	}
+
+	// Unchanged data isn't recorded.
+	err = DataTankSet(tank, func(data *TestData) {})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	history := tank.History()
+	if len(history.Undo) != 3 || len(history.Redo) != 0 {
+		t.Fatalf("Expected 3 undo entries, got %+v", history)
+	}
+
+	if history.Undo[2].Label != "rename" || history.Undo[2].Time.IsZero() {
+		t.Errorf("Expected last entry to be labeled 'rename', got %+v", history.Undo[2])
+	}
+
+	if err := tank.Undo(); err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+
+	if tank.data.Name != "" || tank.data.Count != 4 {
+		t.Errorf("Expected undo to restore Count 4 without a name, got %+v", *tank.data)
+	}
+
+	if err := tank.Undo(); err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+
+	if tank.data.Count != 3 {
+		t.Errorf("Expected Count to be 3, got %d", tank.data.Count)
+	}
+
+	reopened, err := TankStoreOpen[TestData](store, "test-history")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	history = reopened.History()
+	if len(history.Undo) != 1 || len(history.Redo) != 2 || history.Redo[0].Label != "rename" {
+		t.Fatalf("Expected history to survive reopening, got %+v", history)
+	}
+
+	if reopened.data.Count != 3 {
+		t.Errorf("Expected undone state to be saved, got Count %d", reopened.data.Count)
+	}
+
+	if err := reopened.Redo(); err != nil {
+		t.Fatalf("Failed to redo: %v", err)
+	}
+
+	if reopened.data.Count != 4 {
+		t.Errorf("Expected redo to restore Count 4, got %d", reopened.data.Count)
+	}
+
+	err = DataTankSet(reopened, func(data *TestData) {
+		data.Count = 10
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if history := reopened.History(); len(history.Redo) != 0 {
+		t.Errorf("Expected a new change to clear the redo stack, got %+v", history.Redo)
+	}
+}
+
+func TestDataTankHistoryFailedUpdate(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "test-history", DataTankWithHistory(TankHistoryPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	errInvalid := errors.New("invalid")
+	err = DataTankUpdate(tank, func(data *TestData) error {
+		data.Count = 1
+		return errInvalid
+	})
+	if !errors.Is(err, errInvalid) {
+		t.Fatalf("Expected callback error, got %v", err)
+	}
+
+	if history := tank.History(); len(history.Undo) != 0 {
+		t.Errorf("Expected failed update not to be recorded, got %+v", history.Undo)
+	}
+
+	plain, err := TankStoreOpen[TestData](store, "test-plain")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if err := plain.Undo(); err == nil {
+		t.Error("Expected Undo to fail without history")
+	}
+}
+
+type failingTankStorage struct {
+	TankStorage
+	fail string
+}
+
+func (s *failingTankStorage) Write(name string, data []byte) error {
+	if name == s.fail {
+		return errors.New("disk full")
+	}
+
+	return s.TankStorage.Write(name, data)
+}
+
+func TestDataTankHistoryFailedSave(t *testing.T) {
+	storage := &failingTankStorage{TankStorage: TankStorageMemory()}
+	store := TankStoreWithStorage(storage, DataTankWithHistory(TankHistoryPolicy{}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-history")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	storage.fail = "test-history.tank.json"
+
+	err = DataTankUpdate(tank, func(data *TestData) error {
+		data.Count = 1
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected the save to fail")
+	}
+
+	if _, err := storage.Stat("test-history.tank.json.history"); err == nil {
+		t.Error("Expected no history file for a change that wasn't saved")
+	}
+
+	if history := tank.History(); len(history.Undo) != 0 {
+		t.Errorf("Expected failed save not to be recorded, got %+v", history.Undo)
+	}
+
+	// A failing history write doesn't fail the save, it's retried with the next one.
+	var reported error
+	store = TankStoreWithStorage(storage, DataTankWithHistory(TankHistoryPolicy{
+		OnError: func(err error) {
+			reported = err
+		},
+	}))
+
+	tank, err = TankStoreOpen[TestData](store, "test-history")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	storage.fail = "test-history.tank.json.history"
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 2
+	})
+	if err != nil || reported == nil {
+		t.Fatalf("Expected the save to succeed and the history error to be reported, got %v, %v", err, reported)
+	}
+
+	storage.fail = ""
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 3
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	reopened, err := TankStoreOpen[TestData](store, "test-history")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if history := reopened.History(); len(history.Undo) != 2 {
+		t.Errorf("Expected both changes in the saved history, got %+v", history.Undo)
+	}
+}
+
+func TestDataTankHistoryRestore(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(),
+		DataTankWithHistory(TankHistoryPolicy{}),
+		DataTankWithBackups(TankBackupPolicy{}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-history")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	snapshots, err := tank.Snapshots()
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("Expected snapshots, got %v, %v", snapshots, err)
+	}
+
+	if err := tank.Restore(snapshots[0]); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	if tank.data.Count != 1 {
+		t.Fatalf("Expected restore to Count 1, got %d", tank.data.Count)
+	}
+
+	history := tank.History()
+	if len(history.Undo) != 3 || history.Undo[2].Label != "restore" {
+		t.Fatalf("Expected the restore to be recorded, got %+v", history.Undo)
+	}
+
+	if err := tank.Undo(); err != nil {
+		t.Fatalf("Failed to undo restore: %v", err)
+	}
+
+	if tank.data.Count != 2 {
+		t.Errorf("Expected undo to take back the restore, got Count %d", tank.data.Count)
+	}
+}
@@ -0,0 +1,250 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TankCorruptionAction int
+
+const (
+	// Fails loading, the file is left untouched.
+	TankCorruptionFail TankCorruptionAction = iota
+	// Moves the file aside as 'name.tank.<ext>.corrupt-<timestamp>'. Opening the
+	// tank starts from defaults, Reload keeps the data currently in memory and
+	// saves it back.
+	TankCorruptionQuarantine
+	// Loads the newest snapshot that decodes, see DataTankWithBackups. Without
+	// one, loading fails and Reload keeps the data currently in memory.
+	TankCorruptionKeepLastGood
+)
+
+type TankCorruptionKind int
+
+const (
+	TankChecksumMismatch TankCorruptionKind = iota + 1
+	TankDecodeFailed
+)
+
+type TankIntegrityPolicy struct {
+	// Stores a SHA-256 checksum in a '#ktnuity-tank' header line on save and
+	// verifies it on load. Files without the header load unverified.
+	Checksum bool
+	// What to do when the file fails verification or decoding.
+	OnCorruption TankCorruptionAction
+	// Receives the *TankCorruptionError when the corruption was handled
+	// instead of failing the load.
+	Report ErrorConsumerFn
+}
+
+var (
+	// Matches every TankCorruptionError.
+	ErrTankCorrupt = errors.New("tank is corrupt")
+	// Matches TankCorruptionErrors caused by a checksum mismatch.
+	ErrTankChecksumMismatch = errors.New("tank checksum mismatch")
+)
+
+type TankCorruptionError struct {
+	Tank string
+	File string
+	Kind TankCorruptionKind
+	Err  error
+	// Where the file was moved by TankCorruptionQuarantine.
+	QuarantinedAs string
+	// A snapshot was loaded instead by TankCorruptionKeepLastGood.
+	Recovered bool
+}
+
+func (err *TankCorruptionError) Error() string {
+	return fmt.Sprintf("DataTank '%s' file '%s' is corrupt: %v", err.Tank, err.File, err.Err)
+}
+
+func (err *TankCorruptionError) Unwrap() error {
+	return err.Err
+}
+
+func (err *TankCorruptionError) Is(target error) bool {
+	return target == ErrTankCorrupt || (target == ErrTankChecksumMismatch && err.Kind == TankChecksumMismatch)
+}
+
+const tankHeaderPrefix = "#ktnuity-tank "
+
+func DataTankWithIntegrity(policy TankIntegrityPolicy) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.integrity = &policy
+	}
+}
+
+func (d *DataTank[T]) corruption(kind TankCorruptionKind, err error) *TankCorruptionError {
+	return &TankCorruptionError{
+		Tank: d.name,
+		File: d.fileName(),
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+// Splits a '#ktnuity-tank key=value ...' header line off content.
+func parseTankHeader(content []byte) (map[string]string, []byte, bool) {
+	if !bytes.HasPrefix(content, []byte(tankHeaderPrefix)) {
+		return nil, content, false
+	}
+
+	line, payload, found := bytes.Cut(content, []byte("\n"))
+	if !found {
+		return nil, content, false
+	}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(string(line[len(tankHeaderPrefix):])) {
+		key, value, _ := strings.Cut(field, "=")
+		fields[key] = value
+	}
+
+	return fields, payload, true
+}
+
+func formatTankHeader(fields ...string) []byte {
+	return []byte(tankHeaderPrefix + strings.Join(fields, " ") + "\n")
+}
+
+func tankChecksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Prepends the header line if there is anything to put in it. revision is only
+// written with DataTankWithRevisions.
+func (d *DataTank[T]) addHeader(content []byte, revision uint64) []byte {
+	var fields []string
+	if d.config.integrity != nil && d.config.integrity.Checksum {
+		fields = append(fields, "sha256="+tankChecksum(content))
+	}
+
+	if d.config.revisions {
+		fields = append(fields, "rev="+strconv.FormatUint(revision, 10))
+	}
+
+	if len(fields) == 0 {
+		return content
+	}
+
+	return append(formatTankHeader(fields...), content...)
+}
+
+// Strips the header line, verifying the checksum if it has one.
+func (d *DataTank[T]) verifyChecksum(content []byte) ([]byte, error) {
+	fields, payload, found := parseTankHeader(content)
+	if !found {
+		return content, nil
+	}
+
+	expected, exists := fields["sha256"]
+	if !exists {
+		return payload, nil
+	}
+
+	if actual := tankChecksum(payload); actual != expected {
+		return nil, d.corruption(TankChecksumMismatch, fmt.Errorf("%w: expected %s, got %s", ErrTankChecksumMismatch, expected, actual))
+	}
+
+	return payload, nil
+}
+
+// Applies the configured recovery to a failed decode. Returns the data to use
+// instead, or the error to fail with. Returns neither when reloading a
+// quarantined file, the current data is kept then.
+func (d *DataTank[T]) recover(decodeErr error, reloading bool) (*T, error) {
+	var corrupt *TankCorruptionError
+	isCorrupt := errors.As(decodeErr, &corrupt)
+
+	action := TankCorruptionFail
+	if isCorrupt && d.config.integrity != nil {
+		action = d.config.integrity.OnCorruption
+	}
+
+	if action == TankCorruptionFail && d.config.backups != nil && d.config.backups.Fallback {
+		action = TankCorruptionKeepLastGood
+	}
+
+	switch action {
+	case TankCorruptionKeepLastGood:
+		backup := d.loadNewestBackup()
+		if backup == nil {
+			return nil, decodeErr
+		}
+
+		if isCorrupt {
+			corrupt.Recovered = true
+			d.reportCorruption(corrupt)
+		}
+
+		return backup, nil
+	case TankCorruptionQuarantine:
+		name, err := d.quarantine()
+		if err != nil {
+			return nil, fmt.Errorf("failed to quarantine DataTank '%s': %w (%w)", d.name, err, decodeErr)
+		}
+
+		corrupt.QuarantinedAs = name
+		d.reportCorruption(corrupt)
+
+		if reloading {
+			return nil, nil
+		}
+
+		data := new(T)
+		if err := d.defaults(data); err != nil {
+			return nil, err
+		}
+
+		return data, nil
+	}
+
+	return nil, decodeErr
+}
+
+func (d *DataTank[T]) reportCorruption(err *TankCorruptionError) {
+	if d.config.integrity != nil && d.config.integrity.Report != nil {
+		d.config.integrity.Report(err)
+	}
+}
+
+// Moves the tank file, and the journal it belongs to, aside.
+func (d *DataTank[T]) quarantine() (string, error) {
+	suffix := ".corrupt-" + time.Now().UTC().Format(tankBackupTimeFormat)
+
+	names := []string{d.fileName()}
+	if d.config.journal != nil {
+		names = append(names, d.journalName())
+	}
+
+	storage := d.config.storage
+	for _, name := range names {
+		content, err := storage.Read(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if err := storage.Write(name+suffix, content); err != nil {
+			return "", err
+		}
+
+		if err := storage.Delete(name); err != nil {
+			return "", err
+		}
+	}
+
+	return d.fileName() + suffix, nil
+}
@@ -0,0 +1,164 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func testIntegrityTank(t *testing.T, storage TankStorage, policy TankIntegrityPolicy, options ...DataTankOption) *DataTank[TestData] {
+	options = append(options, DataTankWithIntegrity(policy))
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-integrity", options...)
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	return tank
+}
+
+func TestDataTankChecksum(t *testing.T) {
+	storage := TankStorageMemory()
+	tank := testIntegrityTank(t, storage, TankIntegrityPolicy{Checksum: true})
+
+	err := DataTankSet(tank, func(data *TestData) {
+		data.Count = 5
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	content, _ := storage.Read("test-integrity.tank.json")
+	if !strings.HasPrefix(string(content), "#ktnuity-tank sha256=") {
+		t.Fatalf("Expected checksum header, got '%s'", content)
+	}
+
+	reloaded := testIntegrityTank(t, storage, TankIntegrityPolicy{Checksum: true})
+	if reloaded.data.Count != 5 {
+		t.Errorf("Expected Count 5, got %d", reloaded.data.Count)
+	}
+
+	storage.Write("test-integrity.tank.json", []byte(strings.Replace(string(content), `"Count": 5`, `"Count": 6`, 1)))
+
+	_, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-integrity", DataTankWithIntegrity(TankIntegrityPolicy{Checksum: true}))
+	if !errors.Is(err, ErrTankChecksumMismatch) || !errors.Is(err, ErrTankCorrupt) {
+		t.Fatalf("Expected checksum mismatch, got %v", err)
+	}
+
+	var corrupt *TankCorruptionError
+	if !errors.As(err, &corrupt) || corrupt.Kind != TankChecksumMismatch || corrupt.Tank != "test-integrity" {
+		t.Errorf("Expected TankCorruptionError with TankChecksumMismatch, got %+v", corrupt)
+	}
+}
+
+func TestDataTankCorruptionDecodeError(t *testing.T) {
+	storage := TankStorageMemory()
+	storage.Write("test-integrity.tank.json", []byte("{corrupt"))
+
+	_, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-integrity")
+
+	var corrupt *TankCorruptionError
+	if !errors.As(err, &corrupt) || corrupt.Kind != TankDecodeFailed {
+		t.Fatalf("Expected TankCorruptionError with TankDecodeFailed, got %v", err)
+	}
+
+	if errors.Is(err, ErrTankChecksumMismatch) {
+		t.Error("Expected decode failure not to match ErrTankChecksumMismatch")
+	}
+}
+
+func TestDataTankCorruptionQuarantine(t *testing.T) {
+	storage := TankStorageMemory()
+	storage.Write("test-integrity.tank.json", []byte("{corrupt"))
+
+	var reported *TankCorruptionError
+	policy := TankIntegrityPolicy{
+		OnCorruption: TankCorruptionQuarantine,
+		Report: func(err error) {
+			errors.As(err, &reported)
+		},
+	}
+
+	tank := testIntegrityTank(t, storage, policy)
+	if tank.data.Count != 0 || tank.data.Meta == nil {
+		t.Errorf("Expected defaults after quarantine, got %+v", *tank.data)
+	}
+
+	if reported == nil || !strings.HasPrefix(reported.QuarantinedAs, "test-integrity.tank.json.corrupt-") {
+		t.Fatalf("Expected quarantine to be reported, got %+v", reported)
+	}
+
+	if _, err := storage.Stat("test-integrity.tank.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected corrupt file to be moved, got %v", err)
+	}
+
+	content, err := storage.Read(reported.QuarantinedAs)
+	if err != nil || string(content) != "{corrupt" {
+		t.Errorf("Expected quarantined file to keep the corrupt contents, got '%s' (%v)", content, err)
+	}
+}
+
+func TestDataTankCorruptionQuarantineReload(t *testing.T) {
+	for _, journal := range []bool{false, true} {
+		storage := TankStorageMemory()
+		policy := TankIntegrityPolicy{OnCorruption: TankCorruptionQuarantine}
+
+		options := []DataTankOption{}
+		if journal {
+			options = append(options, DataTankWithJournal(TankJournalPolicy{}))
+		}
+
+		tank := testIntegrityTank(t, storage, policy, options...)
+		err := DataTankSet(tank, func(data *TestData) {
+			data.Count = 5
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+
+		storage.Write("test-integrity.tank.json", []byte("{corrupt"))
+
+		if err := tank.Reload(); err != nil || tank.data.Count != 5 {
+			t.Errorf("Expected reload to keep the current data, got Count %d (%v)", tank.data.Count, err)
+		}
+
+		reopened := testIntegrityTank(t, storage, policy, options...)
+		if reopened.data.Count != 5 {
+			t.Errorf("Expected kept data to be saved back with journal %v, got Count %d", journal, reopened.data.Count)
+		}
+	}
+}
+
+func TestDataTankCorruptionKeepLastGood(t *testing.T) {
+	storage := TankStorageMemory()
+	policy := TankIntegrityPolicy{Checksum: true, OnCorruption: TankCorruptionKeepLastGood}
+	backups := DataTankWithBackups(TankBackupPolicy{})
+
+	tank := testIntegrityTank(t, storage, policy, backups)
+	for i := range 2 {
+		DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+	}
+
+	content, _ := storage.Read("test-integrity.tank.json")
+	storage.Write("test-integrity.tank.json", append(content, ' '))
+
+	if err := tank.Reload(); err != nil || tank.data.Count != 1 {
+		t.Errorf("Expected the last good snapshot to be loaded, got Count %d (%v)", tank.data.Count, err)
+	}
+
+	recovered := testIntegrityTank(t, storage, policy, backups)
+	if recovered.data.Count != 1 {
+		t.Errorf("Expected Count from the last good snapshot, got %d", recovered.data.Count)
+	}
+
+	empty := TankStorageMemory()
+	empty.Write("test-integrity.tank.json", append(content, ' '))
+
+	_, err := TankStoreOpen[TestData](TankStoreWithStorage(empty), "test-integrity", DataTankWithIntegrity(policy))
+	if !errors.Is(err, ErrTankChecksumMismatch) {
+		t.Errorf("Expected load to fail without snapshots, got %v", err)
+	}
+}
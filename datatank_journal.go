@@ -0,0 +1,292 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"strconv"
+)
+
+type TankJournalPolicy struct {
+	// Compacts once the journal holds this many records, 0 disables the trigger.
+	CompactRecords int
+	// Compacts once the journal grows past this many bytes, 0 disables the trigger.
+	CompactBytes int64
+}
+
+type tankJournalState struct {
+	// The JSON document the journal currently adds up to.
+	base    any
+	records int
+	size    int64
+	// A torn record was dropped, the journal has to be rewritten.
+	compact bool
+}
+
+const tankJournalDefaultRecords = 1000
+
+// Persists each mutation as a JSON merge patch appended to 'name.tank.<ext>.journal'
+// instead of rewriting the whole tank. The tank file itself is only written on
+// compaction, and loading replays the journal on top of it. If both triggers
+// in policy are 0, compaction happens every 1000 records.
+//
+// Mutations are diffed through encoding/json, so T has to round trip through it
+// regardless of the codec.
+func DataTankWithJournal(policy TankJournalPolicy) DataTankOption {
+	return func(config *dataTankConfig) {
+		if policy.CompactRecords == 0 && policy.CompactBytes == 0 {
+			policy.CompactRecords = tankJournalDefaultRecords
+		}
+
+		config.journal = &policy
+	}
+}
+
+// TankStorageAppender is implemented by storages that can append to a file
+// without rewriting it. Other storages fall back to read and write.
+type TankStorageAppender interface {
+	Append(name string, data []byte) error
+}
+
+func (d *DataTank[T]) journalName() string {
+	return d.fileName() + ".journal"
+}
+
+func encodeJournalDoc[T any](data *T) ([]byte, error) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return content, nil
+}
+
+// Each record is a line holding the CRC-32 of the patch and the patch itself.
+func encodeJournalRecord(patch []byte) []byte {
+	record := make([]byte, 0, len(patch)+10)
+	record = fmt.Appendf(record, "%08x ", crc32.ChecksumIEEE(patch))
+	record = append(record, patch...)
+	return append(record, '\n')
+}
+
+func decodeJournalRecord(line []byte) ([]byte, bool) {
+	sum, patch, found := bytes.Cut(line, []byte(" "))
+	if !found || len(sum) != 8 {
+		return nil, false
+	}
+
+	expected, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || uint32(expected) != crc32.ChecksumIEEE(patch) {
+		return nil, false
+	}
+
+	return patch, true
+}
+
+// Returns nil data if there is no journal. Records after a torn or corrupt one
+// are dropped and the state is flagged for compaction.
+func (d *DataTank[T]) replayJournal(data *T) (*T, tankJournalState, error) {
+	var state tankJournalState
+
+	content, err := d.config.storage.Read(d.journalName())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, state, nil
+	}
+
+	if err != nil {
+		return nil, state, fmt.Errorf("failed to read journal '%s': %v", d.journalName(), err)
+	}
+
+	doc, err := toJsonDoc(data)
+	if err != nil {
+		return nil, state, fmt.Errorf("failed to encode JSON: %v", err)
+	}
+
+	for len(content) > 0 {
+		line, rest, complete := bytes.Cut(content, []byte("\n"))
+		patchContent, valid := decodeJournalRecord(line)
+
+		if !complete || !valid {
+			state.compact = true
+			break
+		}
+
+		patchContent, err = d.openRecord(patchContent)
+		if err != nil {
+			return nil, state, err
+		}
+
+		patch, err := decodeJsonDoc(patchContent)
+		if err != nil {
+			state.compact = true
+			break
+		}
+
+		doc = mergePatchApply(doc, patch)
+		state.records++
+		state.size += int64(len(line) + 1)
+		content = rest
+	}
+
+	content, err = json.Marshal(doc)
+	if err != nil {
+		return nil, state, fmt.Errorf("failed to encode JSON: %v", err)
+	}
+
+	replayed := new(T)
+	if err := d.defaults(replayed); err != nil {
+		return nil, state, err
+	}
+
+	if err := json.Unmarshal(content, replayed); err != nil {
+		return nil, state, fmt.Errorf("failed to decode journal '%s': %v", d.journalName(), err)
+	}
+
+	return replayed, state, nil
+}
+
+// Must be called with saveMu held. content is the JSON encoding of the new state.
+func (d *DataTank[T]) appendJournal(content []byte) error {
+	doc, err := decodeJsonDoc(content)
+	if err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	patch := mergePatchDiff(d.journal.base, doc)
+	if empty, ok := patch.(map[string]any); ok && len(empty) == 0 && d.journal.base != nil {
+		if d.journal.compact {
+			return d.compactJournal(content, doc)
+		}
+
+		return nil
+	}
+
+	if mergePatchDropsNull(patch, doc) {
+		return d.compactJournal(content, doc)
+	}
+
+	patchContent, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	patchContent, err = d.sealRecord(patchContent)
+	if err != nil {
+		return err
+	}
+
+	record := encodeJournalRecord(patchContent)
+	if err := d.appendStorage(d.journalName(), record); err != nil {
+		return fmt.Errorf("failed to append to journal '%s': %w", d.journalName(), err)
+	}
+
+	d.journal.base = doc
+	d.journal.records++
+	d.journal.size += int64(len(record))
+
+	policy := d.config.journal
+	if d.journal.compact ||
+		(policy.CompactRecords > 0 && d.journal.records >= policy.CompactRecords) ||
+		(policy.CompactBytes > 0 && d.journal.size >= policy.CompactBytes) {
+		return d.compactJournal(content, doc)
+	}
+
+	return nil
+}
+
+// Reports whether patch sets a member of doc, the document it leads to, to null.
+// Merge patches turn those into removals, which replay would leave at their
+// defaults, so they are compacted into the tank file instead.
+func mergePatchDropsNull(patch, doc any) bool {
+	patchMap, ok := patch.(map[string]any)
+	docMap, isMap := doc.(map[string]any)
+	if !ok || !isMap {
+		return false
+	}
+
+	for key, value := range patchMap {
+		current, exists := docMap[key]
+		if value == nil && exists {
+			return true
+		}
+
+		if value != nil && mergePatchDropsNull(value, current) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Writes the full state to the tank file and empties the journal. A crash in
+// between is harmless, replaying merge patches over their own result is a no-op.
+func (d *DataTank[T]) compactJournal(content []byte, doc any) error {
+	data := new(T)
+	if err := json.Unmarshal(content, data); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	snapshot, err := d.encode(data)
+	if err != nil {
+		return err
+	}
+
+	if err := d.write(snapshot, false); err != nil {
+		return err
+	}
+
+	if err := d.config.storage.Delete(d.journalName()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to truncate journal '%s': %w", d.journalName(), err)
+	}
+
+	d.journal = tankJournalState{base: doc}
+	return nil
+}
+
+// Forces a compaction of the journal into the tank file.
+func (d *DataTank[T]) Compact() error {
+	if d.config.journal == nil {
+		return d.Save()
+	}
+
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.RLock()
+	content, err := d.prepare(d.data)
+	if err != nil {
+		d.mu.RUnlock()
+		return err
+	}
+
+	d.saveMu.Lock()
+	d.mu.RUnlock()
+	defer d.saveMu.Unlock()
+
+	doc, err := decodeJsonDoc(content)
+	if err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	defer d.recordFingerprint()
+	return d.compactJournal(content, doc)
+}
+
+func (d *DataTank[T]) appendStorage(name string, data []byte) error {
+	if appender, ok := d.config.storage.(TankStorageAppender); ok {
+		return appender.Append(name, data)
+	}
+
+	previous, err := d.config.storage.Read(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return d.config.storage.Write(name, append(previous, data...))
+}
@@ -0,0 +1,254 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestDataTankJournalAppends(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithJournal(TankJournalPolicy{CompactRecords: 100}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-journal")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := range 5 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+			data.Meta["last"] = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	if _, err := storage.Stat("test-journal.tank.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected no tank file before compaction, got %v", err)
+	}
+
+	journal, err := storage.Read("test-journal.tank.json.journal")
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+
+	if lines := strings.Count(string(journal), "\n"); lines != 5 {
+		t.Errorf("Expected 5 journal records, got %d", lines)
+	}
+
+	reloaded, err := TankStoreOpen[TestData](store, "test-journal")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 5 || reloaded.data.Meta["last"] != 4 {
+		t.Errorf("Expected journal to be replayed, got %+v", *reloaded.data)
+	}
+}
+
+func TestDataTankJournalCompaction(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithJournal(TankJournalPolicy{CompactRecords: 3}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-compact")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := range 4 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Items = append(data.Items, "item")
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	journal, err := storage.Read("test-compact.tank.json.journal")
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+
+	if lines := strings.Count(string(journal), "\n"); lines != 1 {
+		t.Errorf("Expected a single record after compaction, got %d", lines)
+	}
+
+	snapshot, err := storage.Read("test-compact.tank.json")
+	if err != nil || !strings.Contains(string(snapshot), `"Count": 3`) {
+		t.Errorf("Expected compacted snapshot with Count 3, got '%s' (%v)", snapshot, err)
+	}
+
+	reloaded, err := TankStoreOpen[TestData](store, "test-compact")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 4 || len(reloaded.data.Items) != 4 {
+		t.Errorf("Expected snapshot plus journal, got %+v", *reloaded.data)
+	}
+}
+
+func TestDataTankJournalTornRecord(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithJournal(TankJournalPolicy{}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-torn")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := range 2 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	storage.(TankStorageAppender).Append("test-torn.tank.json.journal", []byte(`0badc0de {"Count": 9`))
+
+	reloaded, err := TankStoreOpen[TestData](store, "test-torn")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 2 {
+		t.Errorf("Expected torn record to be discarded, got Count %d", reloaded.data.Count)
+	}
+
+	if _, err := storage.Stat("test-torn.tank.json.journal"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected journal to be compacted after a torn record, got %v", err)
+	}
+
+	err = DataTankSet(reloaded, func(data *TestData) {
+		data.Count = 3
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	again, err := TankStoreOpen[TestData](store, "test-torn")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if again.data.Count != 3 {
+		t.Errorf("Expected Count 3 after appending past a torn record, got %d", again.data.Count)
+	}
+}
+
+type TestDataSnowflake struct {
+	ID     int64
+	Name   string
+	Owners map[string]uint64
+}
+
+func TestDataTankJournalLargeIntegers(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithJournal(TankJournalPolicy{CompactRecords: 100}))
+
+	tank, err := TankStoreOpen[TestDataSnowflake](store, "test-snowflake")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataSnowflake) {
+		data.ID = 1234567890123456789
+		data.Owners["a"] = 18446744073709551615
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataSnowflake) {
+		data.Owners["b"] = 1234567890123456788
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	reloaded, err := TankStoreOpen[TestDataSnowflake](store, "test-snowflake")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.ID != 1234567890123456789 {
+		t.Errorf("Expected ID 1234567890123456789, got %d", reloaded.data.ID)
+	}
+
+	if reloaded.data.Owners["a"] != 18446744073709551615 || reloaded.data.Owners["b"] != 1234567890123456788 {
+		t.Errorf("Expected owners to keep their exact values, got %v", reloaded.data.Owners)
+	}
+
+	if err := reloaded.Compact(); err != nil {
+		t.Fatalf("Failed to compact DataTank: %v", err)
+	}
+
+	compacted, err := TankStoreOpen[TestDataSnowflake](store, "test-snowflake")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if compacted.data.ID != 1234567890123456789 {
+		t.Errorf("Expected ID to survive compaction, got %d", compacted.data.ID)
+	}
+}
+
+type TestDataNullable struct {
+	P    *int `default:"5"`
+	Meta map[string]int
+}
+
+func TestDataTankJournalNulls(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithJournal(TankJournalPolicy{CompactRecords: 100}))
+
+	tank, err := TankStoreOpen[TestDataNullable](store, "test-journal-null")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataNullable) {
+		data.Meta["a"] = 1
+		data.Meta["b"] = 2
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	// Removed map keys are plain removals and stay in the journal.
+	err = DataTankSet(tank, func(data *TestDataNullable) {
+		delete(data.Meta, "a")
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if tank.journal.records != 2 {
+		t.Errorf("Expected 2 journal records, got %d", tank.journal.records)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataNullable) {
+		data.P = nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	reloaded, err := TankStoreOpen[TestDataNullable](store, "test-journal-null")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.P != nil {
+		t.Errorf("Expected the saved null to stay, got %d", *reloaded.data.P)
+	}
+
+	if _, exists := reloaded.data.Meta["a"]; exists || reloaded.data.Meta["b"] != 2 {
+		t.Errorf("Expected only 'b' in Meta, got %v", reloaded.data.Meta)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package ktnuitygo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func lockFile(path string) (func(), error) {
+	return nil, fmt.Errorf("file locking is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package ktnuitygo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockFileExclusive(t *testing.T) {
+	path := t.TempDir() + "/test.tank.lock"
+
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("Failed to lock file: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := lockFile(path)
+		if err != nil {
+			t.Errorf("Failed to lock file: %v", err)
+			close(acquired)
+			return
+		}
+		close(acquired)
+		unlock()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected second lock to block while the first is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected second lock to be acquired after release")
+	}
+}
+
+func TestDataTankFileLockReadModifyWrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	first, err := DataTankNew[TestData]("test-shared", DataTankWithFileLock())
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	second, err := DataTankNew[TestData]("test-shared", DataTankWithFileLock())
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	for range 5 {
+		for _, tank := range []*DataTank[TestData]{first, second} {
+			err := DataTankSet(tank, func(data *TestData) {
+				data.Count++
+			})
+			if err != nil {
+				t.Fatalf("Failed to set DataTank data: %v", err)
+			}
+		}
+	}
+
+	reloaded, err := DataTankNew[TestData]("test-shared")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 10 {
+		t.Errorf("Expected Count to be 10 after interleaved sets, got %d", reloaded.data.Count)
+	}
+}
@@ -0,0 +1,93 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Applies an RFC 6902 JSON patch to the tank data, with the semantics of
+// DataTankUpdate. The patched document has to decode into T without unknown
+// fields or type mismatches, otherwise nothing is changed. T has to round trip
+// through encoding/json regardless of the codec.
+func DataTankApplyPatch[T any](d *DataTank[T], patch []byte) error {
+	var ops JsonPatch
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return fmt.Errorf("failed to decode JSON patch for DataTank '%s': %w", d.name, err)
+	}
+
+	return dataTankPatch(d, "JSON patch", func(doc any) (any, error) {
+		return jsonPatchApply(doc, ops)
+	})
+}
+
+// Applies an RFC 7396 merge patch to the tank data, same as DataTankApplyPatch.
+func DataTankApplyMergePatch[T any](d *DataTank[T], patch []byte) error {
+	doc, err := decodeJsonDoc(patch)
+	if err != nil {
+		return fmt.Errorf("failed to decode merge patch for DataTank '%s': %w", d.name, err)
+	}
+
+	return dataTankPatch(d, "merge patch", func(target any) (any, error) {
+		return mergePatchApply(target, doc), nil
+	})
+}
+
+func dataTankPatch[T any](d *DataTank[T], kind string, apply func(doc any) (any, error)) error {
+	return DataTankUpdate(d, func(data *T) error {
+		doc, err := toJsonDoc(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode DataTank '%s' for %s: %w", d.name, kind, err)
+		}
+
+		doc, err = apply(doc)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s to DataTank '%s': %w", kind, d.name, err)
+		}
+
+		patched, err := decodeJsonDocStrict[T](doc)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s to DataTank '%s': %w", kind, d.name, err)
+		}
+
+		if err := d.verify(patched); err != nil {
+			return err
+		}
+
+		*data = *patched
+		return nil
+	})
+}
+
+func decodeJsonDocStrict[T any](doc any) (*T, error) {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	result := new(T)
+	if err := decoder.Decode(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Returns the RFC 6902 JSON patch turning from into to, as seen through
+// encoding/json.
+func DataTankDiff[T any](from *T, to *T) (JsonPatch, error) {
+	fromDoc, err := toJsonDoc(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode diff source: %w", err)
+	}
+
+	toDoc, err := toJsonDoc(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode diff target: %w", err)
+	}
+
+	return jsonPatchDiff(fromDoc, toDoc), nil
+}
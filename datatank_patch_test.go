@@ -0,0 +1,192 @@
+package ktnuitygo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDataTankApplyPatch(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "test-patch")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankApplyPatch(tank, []byte(`[
+		{"op":"replace","path":"/Name","value":"patched"},
+		{"op":"add","path":"/Items/-","value":"a"},
+		{"op":"add","path":"/Meta/x","value":1}
+	]`))
+	if err != nil {
+		t.Fatalf("Failed to apply patch: %v", err)
+	}
+
+	if tank.data.Name != "patched" || len(tank.data.Items) != 1 || tank.data.Meta["x"] != 1 {
+		t.Errorf("Expected patch to be applied, got %+v", *tank.data)
+	}
+
+	reopened, err := TankStoreOpen[TestData](store, "test-patch")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if reopened.data.Name != "patched" {
+		t.Errorf("Expected patch to be saved, got Name '%s'", reopened.data.Name)
+	}
+
+	failing := []string{
+		// Fails halfway through, the first operation must not stick.
+		`[{"op":"replace","path":"/Name","value":"half"},{"op":"test","path":"/Count","value":5}]`,
+		`[{"op":"add","path":"/Unknown","value":1}]`,
+		`[{"op":"replace","path":"/Count","value":"many"}]`,
+		`not json`,
+	}
+
+	for _, patch := range failing {
+		if err := DataTankApplyPatch(tank, []byte(patch)); err == nil {
+			t.Errorf("Expected patch %s to fail", patch)
+		}
+	}
+
+	if tank.data.Name != "patched" || tank.data.Count != 0 {
+		t.Errorf("Expected failed patches to leave the tank unchanged, got %+v", *tank.data)
+	}
+}
+
+func TestDataTankApplyMergePatch(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "test-merge-patch")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Meta["x"] = 1
+		data.Meta["y"] = 2
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	err = DataTankApplyMergePatch(tank, []byte(`{"Count":3,"Meta":{"x":null,"z":4}}`))
+	if err != nil {
+		t.Fatalf("Failed to apply merge patch: %v", err)
+	}
+
+	if tank.data.Count != 3 || len(tank.data.Meta) != 2 || tank.data.Meta["z"] != 4 {
+		t.Errorf("Expected merge patch to be applied, got %+v", *tank.data)
+	}
+
+	// Removing Items entirely still leaves an initialized slice.
+	err = DataTankApplyMergePatch(tank, []byte(`{"Items":null}`))
+	if err != nil {
+		t.Fatalf("Failed to apply merge patch: %v", err)
+	}
+
+	if tank.data.Items == nil {
+		t.Error("Expected Items to be initialized after patching")
+	}
+
+	errInvalid := errors.New("invalid")
+	validated, err := TankStoreOpen[TestData](store, "test-merge-validated", DataTankWithValidator(func(data *TestData) error {
+		if data.Count < 0 {
+			return errInvalid
+		}
+
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if err := DataTankApplyMergePatch(validated, []byte(`{"Count":-1}`)); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+}
+
+func TestDataTankDiff(t *testing.T) {
+	from := TestData{Name: "a", Items: []string{"x"}, Meta: map[string]int{"k": 1}}
+	to := TestData{Name: "b", Items: []string{"x", "y"}, Meta: map[string]int{}}
+
+	patch, err := DataTankDiff(&from, &to)
+	if err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+
+	store := TankStoreWithStorage(TankStorageMemory())
+	tank, err := TankStoreOpen[TestData](store, "test-diff")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		*data = from
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	content, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatalf("Failed to encode diff: %v", err)
+	}
+
+	if err := DataTankApplyPatch(tank, content); err != nil {
+		t.Fatalf("Failed to apply diff %s: %v", content, err)
+	}
+
+	if tank.data.Name != "b" || len(tank.data.Items) != 2 || len(tank.data.Meta) != 0 {
+		t.Errorf("Expected diff to turn from into to, got %+v", *tank.data)
+	}
+}
+
+func TestDataTankPatchLargeIntegers(t *testing.T) {
+	const id int64 = 1234567890123456789
+
+	store := TankStoreWithStorage(TankStorageMemory())
+	tank, err := TankStoreOpen[TestDataSnowflake](store, "test-patch-snowflake")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataSnowflake) {
+		data.ID = id
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if err := DataTankApplyMergePatch(tank, []byte(`{"Name":"x"}`)); err != nil {
+		t.Fatalf("Failed to apply merge patch: %v", err)
+	}
+
+	if tank.data.ID != id || tank.data.Name != "x" {
+		t.Errorf("Expected ID %d and Name 'x', got %+v", id, *tank.data)
+	}
+
+	// Differs from the ID only below float64 precision.
+	err = DataTankApplyPatch(tank, []byte(`[{"op":"test","path":"/ID","value":1234567890123456788}]`))
+	if !errors.Is(err, ErrJsonPatchTestFailed) {
+		t.Errorf("Expected test against a neighbouring integer to fail, got %v", err)
+	}
+
+	err = DataTankApplyPatch(tank, []byte(`[{"op":"test","path":"/ID","value":1234567890123456789},{"op":"replace","path":"/Name","value":"y"}]`))
+	if err != nil {
+		t.Errorf("Expected test against the ID to pass, got %v", err)
+	}
+
+	from := TestDataSnowflake{ID: id}
+	to := TestDataSnowflake{ID: id + 1}
+
+	patch, err := DataTankDiff(&from, &to)
+	if err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+
+	if len(patch) != 1 || patch[0].Path != "/ID" {
+		t.Errorf("Expected the diff to replace /ID, got %+v", patch)
+	}
+}
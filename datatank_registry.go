@@ -0,0 +1,299 @@
+package ktnuitygo
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrTankNameInvalid = errors.New("invalid tank name")
+
+type TankInfo struct {
+	Name string
+	// The codec extension of the tank file, e.g. 'json'.
+	Extension string
+	Size      int64
+	ModTime   time.Time
+}
+
+// Rejects names that could resolve outside of the tank directory. Names may
+// still reach into subdirectories with '/'.
+func validateTankName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is empty", ErrTankNameInvalid)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("%w '%s': must not contain NUL", ErrTankNameInvalid, name)
+	}
+
+	elements := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+
+	if slices.Contains(elements, "..") {
+		return fmt.Errorf("%w '%s': must not contain '..'", ErrTankNameInvalid, name)
+	}
+
+	return nil
+}
+
+// Splits a file name into the tank it belongs to and its codec extension. Only
+// tank files and journals are recognized, not other sidecar files.
+func parseTankFileName(file string) (name string, extension string, journal bool, ok bool) {
+	index := strings.LastIndex(file, ".tank.")
+	if index <= 0 {
+		return "", "", false, false
+	}
+
+	name, extension = file[:index], file[index+len(".tank."):]
+	extension, journal = strings.CutSuffix(extension, ".journal")
+	if extension == "" || extension == "lock" || extension == "d" || strings.Contains(extension, ".") || validateTankName(name) != nil {
+		return "", "", false, false
+	}
+
+	return name, extension, journal, true
+}
+
+// Returns the codec extension of the tank name as found in the storage. The
+// store's own codec is checked first, then the files of any other codec the
+// tank may have been saved with. Fails with fs.ErrNotExist if there's none.
+func (s *TankStore) extension(name string) (string, error) {
+	extension := s.config().codec.Extension()
+
+	exists, err := s.exists(name, extension)
+	if err != nil || exists {
+		return extension, err
+	}
+
+	files, err := s.storage.List()
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		if found, extension, _, ok := parseTankFileName(file.Name); ok && found == name {
+			return extension, nil
+		}
+	}
+
+	return "", &fs.PathError{Op: "open", Path: tankFileName(name, extension), Err: fs.ErrNotExist}
+}
+
+// Reports whether the tank name has a file or journal with extension.
+func (s *TankStore) exists(name string, extension string) (bool, error) {
+	main := tankFileName(name, extension)
+
+	// Tanks in journal mode may only have a journal so far.
+	for _, file := range []string{main, main + ".journal"} {
+		_, err := s.storage.Stat(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Lists the tanks in the store, sorted by name. Sidecar files such as backups
+// and locks are not listed on their own, journals only when there's no tank
+// file yet.
+func (s *TankStore) List() ([]TankInfo, error) {
+	files, err := s.storage.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tanks: %w", err)
+	}
+
+	result := make([]TankInfo, 0, len(files))
+	indices := make(map[string]int)
+	for _, file := range files {
+		name, extension, journal, ok := parseTankFileName(file.Name)
+		if !ok {
+			continue
+		}
+
+		info := TankInfo{
+			Name:      name,
+			Extension: extension,
+			Size:      file.Size,
+			ModTime:   file.ModTime,
+		}
+
+		key := tankFileName(name, extension)
+		if existing, ok := indices[key]; ok {
+			if !journal {
+				result[existing] = info
+			}
+
+			continue
+		}
+
+		indices[key] = len(result)
+		result = append(result, info)
+	}
+
+	slices.SortFunc(result, func(a, b TankInfo) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Extension, b.Extension))
+	})
+
+	return result, nil
+}
+
+// Reports whether the tank name exists, saved with any codec.
+func (s *TankStore) Exists(name string) (bool, error) {
+	if err := validateTankName(name); err != nil {
+		return false, err
+	}
+
+	_, err := s.extension(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to stat DataTank '%s': %w", name, err)
+	}
+
+	return true, nil
+}
+
+// Returns the files of the tank name, its tank file or journal first, along
+// with the extension they were found under.
+func (s *TankStore) files(name string) ([]string, string, error) {
+	extension, err := s.extension(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files, err := s.storage.List()
+	if err != nil {
+		return nil, "", err
+	}
+
+	main := tankFileName(name, extension)
+	result := make([]string, 0, 1)
+	for _, file := range files {
+		if file.Name == main || file.Name == main+".journal" {
+			result = slices.Insert(result, 0, file.Name)
+		} else if strings.HasPrefix(file.Name, main+".") {
+			result = append(result, file.Name)
+		}
+	}
+
+	return result, extension, nil
+}
+
+// Deletes the tank along with its journal and backups. Tanks that are still
+// open keep their data in memory and will write it back on the next save.
+func (s *TankStore) Delete(name string) error {
+	if err := validateTankName(name); err != nil {
+		return err
+	}
+
+	files, _, err := s.files(name)
+	if err != nil {
+		return fmt.Errorf("failed to delete DataTank '%s': %w", name, err)
+	}
+
+	// The tank file and journal go last, so an interrupted delete still leaves a tank.
+	for _, file := range slices.Backward(files) {
+		if err := s.storage.Delete(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete DataTank '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Copies the tank file and journal of src to a new tank dst.
+func (s *TankStore) Copy(src string, dst string) error {
+	return s.transfer(src, dst, false)
+}
+
+// Moves the tank src along with its journal and backups to a new tank dst.
+func (s *TankStore) Rename(src string, dst string) error {
+	return s.transfer(src, dst, true)
+}
+
+// Files are written to dst before anything of src is deleted, so a failure
+// never loses the tank.
+func (s *TankStore) transfer(src string, dst string, move bool) error {
+	action := "copy"
+	if move {
+		action = "rename"
+	}
+
+	if err := validateTankName(src); err != nil {
+		return err
+	}
+
+	if err := validateTankName(dst); err != nil {
+		return err
+	}
+
+	exists, err := s.Exists(dst)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("failed to %s DataTank '%s': '%s' %w", action, src, dst, fs.ErrExist)
+	}
+
+	files, extension, err := s.files(src)
+	if err != nil {
+		return fmt.Errorf("failed to %s DataTank '%s': %w", action, src, err)
+	}
+
+	srcMain, dstMain := tankFileName(src, extension), tankFileName(dst, extension)
+	for _, file := range files {
+		if !move && strings.HasSuffix(file, ".bak") {
+			continue
+		}
+
+		content, err := s.storage.Read(file)
+		if err != nil {
+			return fmt.Errorf("failed to %s DataTank '%s': %w", action, src, err)
+		}
+
+		target := dstMain + strings.TrimPrefix(file, srcMain)
+		if err := s.storage.Write(target, content); err != nil {
+			return fmt.Errorf("failed to %s DataTank '%s': %w", action, src, err)
+		}
+	}
+
+	if move {
+		return s.Delete(src)
+	}
+
+	return nil
+}
+
+func DataTankList() ([]TankInfo, error) {
+	return defaultTankStore.List()
+}
+
+func DataTankExists(name string) (bool, error) {
+	return defaultTankStore.Exists(name)
+}
+
+func DataTankDelete(name string) error {
+	return defaultTankStore.Delete(name)
+}
+
+func DataTankCopy(src string, dst string) error {
+	return defaultTankStore.Copy(src, dst)
+}
+
+func DataTankRename(src string, dst string) error {
+	return defaultTankStore.Rename(src, dst)
+}
@@ -0,0 +1,229 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestTankRegistryList(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := TankStoreNew(tmpDir)
+
+	tank, err := TankStoreOpen[TestData](store, "beta", DataTankWithBackups(TankBackupPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	for range 2 {
+		if err := tank.Save(); err != nil {
+			t.Fatalf("Failed to save DataTank: %v", err)
+		}
+	}
+
+	// Only has a journal so far.
+	journaled, err := TankStoreOpen[TestData](store, "alpha", DataTankWithJournal(TankJournalPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(journaled, func(data *TestData) {
+		data.Count = 1
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if err := os.WriteFile(tmpDir+"/other.txt", []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tanks, err := store.List()
+	if err != nil {
+		t.Fatalf("Failed to list tanks: %v", err)
+	}
+
+	if len(tanks) != 2 || tanks[0].Name != "alpha" || tanks[1].Name != "beta" {
+		t.Fatalf("Expected tanks [alpha beta], got %+v", tanks)
+	}
+
+	if tanks[0].Extension != "json" || tanks[0].Size == 0 || tanks[0].ModTime.IsZero() {
+		t.Errorf("Expected tank info to be filled in, got %+v", tanks[0])
+	}
+}
+
+func TestTankRegistryExistsAndDelete(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "tank", DataTankWithBackups(TankBackupPolicy{MaxCount: 3}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	for range 2 {
+		if err := tank.Save(); err != nil {
+			t.Fatalf("Failed to save DataTank: %v", err)
+		}
+	}
+
+	exists, err := store.Exists("tank")
+	if err != nil || !exists {
+		t.Fatalf("Expected tank to exist, got %v, %v", exists, err)
+	}
+
+	if err := store.Delete("tank"); err != nil {
+		t.Fatalf("Failed to delete tank: %v", err)
+	}
+
+	exists, err = store.Exists("tank")
+	if err != nil || exists {
+		t.Errorf("Expected tank to be gone, got %v, %v", exists, err)
+	}
+
+	files, _ := store.Storage().List()
+	if len(files) != 0 {
+		t.Errorf("Expected backups to be deleted too, got %+v", files)
+	}
+
+	if err := store.Delete("tank"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist deleting a missing tank, got %v", err)
+	}
+}
+
+func TestTankRegistryRenameAndCopy(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "old", DataTankWithJournal(TankJournalPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Name = "renamed"
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if err := store.Rename("old", "new"); err != nil {
+		t.Fatalf("Failed to rename tank: %v", err)
+	}
+
+	if exists, _ := store.Exists("old"); exists {
+		t.Error("Expected old tank to be gone after rename")
+	}
+
+	renamed, err := TankStoreOpen[TestData](store, "new", DataTankWithJournal(TankJournalPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to open renamed DataTank: %v", err)
+	}
+
+	if renamed.data.Name != "renamed" {
+		t.Errorf("Expected Name to be 'renamed', got '%s'", renamed.data.Name)
+	}
+
+	if err := store.Copy("new", "copy"); err != nil {
+		t.Fatalf("Failed to copy tank: %v", err)
+	}
+
+	copied, err := TankStoreOpen[TestData](store, "copy", DataTankWithJournal(TankJournalPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to open copied DataTank: %v", err)
+	}
+
+	if copied.data.Name != "renamed" {
+		t.Errorf("Expected copied Name to be 'renamed', got '%s'", copied.data.Name)
+	}
+
+	if exists, _ := store.Exists("new"); !exists {
+		t.Error("Expected source tank to remain after copy")
+	}
+
+	if err := store.Copy("new", "copy"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Expected fs.ErrExist copying over an existing tank, got %v", err)
+	}
+}
+
+func TestTankRegistryNameValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := TankStoreNew(tmpDir)
+
+	for _, name := range []string{"", "../../etc/passwd", "a/../../b", "a\\..\\b", "..", "a\x00b"} {
+		if _, err := store.Exists(name); !errors.Is(err, ErrTankNameInvalid) {
+			t.Errorf("Expected ErrTankNameInvalid for '%s', got %v", name, err)
+		}
+
+		if _, err := TankStoreOpen[TestData](store, name); !errors.Is(err, ErrTankNameInvalid) {
+			t.Errorf("Expected ErrTankNameInvalid opening '%s', got %v", name, err)
+		}
+	}
+
+	if err := store.Rename("a", "../b"); !errors.Is(err, ErrTankNameInvalid) {
+		t.Errorf("Expected ErrTankNameInvalid renaming to '../b', got %v", err)
+	}
+
+	if err := os.Mkdir(tmpDir+"/sub", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	// Subdirectories and leading dots stay inside the directory.
+	for _, name := range []string{"sub/name", ".hidden"} {
+		tank, err := TankStoreOpen[TestData](store, name)
+		if err != nil {
+			t.Fatalf("Failed to open DataTank '%s': %v", name, err)
+		}
+
+		if err := tank.Save(); err != nil {
+			t.Fatalf("Failed to save DataTank '%s': %v", name, err)
+		}
+
+		if exists, err := store.Exists(name); err != nil || !exists {
+			t.Errorf("Expected '%s' to exist, got %v, %v", name, exists, err)
+		}
+	}
+
+	if _, err := os.Stat(tmpDir + "/sub/name.tank.json"); err != nil {
+		t.Errorf("Expected tank file in the subdirectory, got %v", err)
+	}
+}
+
+func TestTankRegistryOtherCodec(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "settings", DataTankWithCodec(TankCodecYAML))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if err := tank.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	if exists, err := store.Exists("settings"); err != nil || !exists {
+		t.Fatalf("Expected YAML tank to exist, got %v, %v", exists, err)
+	}
+
+	if err := store.Copy("settings", "copy"); err != nil {
+		t.Fatalf("Failed to copy YAML tank: %v", err)
+	}
+
+	if _, err := store.Storage().Stat("copy.tank.yaml"); err != nil {
+		t.Errorf("Expected copy to keep the YAML extension, got %v", err)
+	}
+
+	if err := store.Rename("copy", "moved"); err != nil {
+		t.Fatalf("Failed to rename YAML tank: %v", err)
+	}
+
+	for _, name := range []string{"settings", "moved"} {
+		if err := store.Delete(name); err != nil {
+			t.Errorf("Failed to delete YAML tank '%s': %v", name, err)
+		}
+	}
+
+	files, _ := store.Storage().List()
+	if len(files) != 0 {
+		t.Errorf("Expected all files to be deleted, got %+v", files)
+	}
+}
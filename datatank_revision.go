@@ -0,0 +1,182 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"time"
+)
+
+// The state of a tank file as of the last load or save.
+type TankRevision struct {
+	// Counts up with every write of the tank file, 0 if it has none yet.
+	Number  uint64
+	ModTime time.Time
+	// SHA-256 of the file as stored, empty if there is no file.
+	Hash string
+}
+
+func (r TankRevision) matches(other TankRevision) bool {
+	return r.Number == other.Number && r.Hash == other.Hash
+}
+
+var ErrTankConflict = errors.New("tank was changed by another writer")
+
+// Returned by conditional saves when the tank file changed since it was loaded
+// or last saved by this tank.
+type TankConflictError struct {
+	Tank     string
+	Expected TankRevision
+	Actual   TankRevision
+}
+
+func (err *TankConflictError) Error() string {
+	return fmt.Sprintf("DataTank '%s' was changed by another writer: expected revision %d, found %d", err.Tank, err.Expected.Number, err.Actual.Number)
+}
+
+func (err *TankConflictError) Is(target error) bool {
+	return target == ErrTankConflict
+}
+
+// Stores a revision number in the '#ktnuity-tank' header line, which goes up by
+// one with every write of the tank file, and tracks the file's mtime and hash.
+// Required by SaveIfUnchanged and DataTankUpdateIfUnchanged. In journal mode the
+// tank file, and so its revision, only changes on compaction.
+func DataTankWithRevisions() DataTankOption {
+	return func(config *dataTankConfig) {
+		config.revisions = true
+	}
+}
+
+// Returns the revision of the tank file as of the last load or save.
+func (d *DataTank[T]) Revision() TankRevision {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	return d.revision
+}
+
+// The revision of content as read from the tank file, readErr being the error
+// reading it.
+func (d *DataTank[T]) revisionOf(content []byte, readErr error) TankRevision {
+	if !d.config.revisions || readErr != nil {
+		return TankRevision{}
+	}
+
+	revision := TankRevision{Hash: tankChecksum(content)}
+
+	if info, err := d.config.storage.Stat(d.fileName()); err == nil {
+		revision.ModTime = info.ModTime
+	}
+
+	// Unreadable headers count as revision 0, the hash still tells them apart.
+	if opened, err := d.open(content); err == nil {
+		if fields, _, found := parseTankHeader(opened); found {
+			revision.Number, _ = strconv.ParseUint(fields["rev"], 10, 64)
+		}
+	}
+
+	return revision
+}
+
+// Returns the revision number for the next write. Must be called with saveMu
+// held. With conditional, fails if the file no longer matches d.revision.
+func (d *DataTank[T]) nextRevision(conditional bool) (uint64, error) {
+	if !d.config.revisions {
+		return 0, nil
+	}
+
+	content, err := d.config.storage.Read(d.fileName())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read revision of DataTank '%s': %w", d.name, err)
+	}
+
+	current := d.revisionOf(content, err)
+	if conditional && !current.matches(d.revision) {
+		return 0, &TankConflictError{
+			Tank:     d.name,
+			Expected: d.revision,
+			Actual:   current,
+		}
+	}
+
+	// Stays monotonic even when someone else wrote in the meantime.
+	return max(current.Number, d.revision.Number) + 1, nil
+}
+
+// Must be called with saveMu held, after content was written as revision.
+func (d *DataTank[T]) recordRevision(revision uint64, content []byte) {
+	if !d.config.revisions {
+		return
+	}
+
+	d.revision = TankRevision{
+		Number: revision,
+		Hash:   tankChecksum(content),
+	}
+
+	if info, err := d.config.storage.Stat(d.fileName()); err == nil {
+		d.revision.ModTime = info.ModTime
+	}
+}
+
+func (d *DataTank[T]) requireRevisions() error {
+	if !d.config.revisions {
+		return fmt.Errorf("failed to save DataTank '%s': conditional saves require DataTankWithRevisions", d.name)
+	}
+
+	return nil
+}
+
+// Like Save, but fails with a TankConflictError if the tank file changed since
+// it was loaded or last saved by this tank. Without DataTankWithFileLock, a
+// writer slipping in between the check and the write goes undetected.
+func (d *DataTank[T]) SaveIfUnchanged() error {
+	if err := d.requireRevisions(); err != nil {
+		return err
+	}
+
+	unlockFile, err := d.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlockFile()
+
+	d.mu.RLock()
+	d.checkMutations()
+
+	if err := d.validate(d.data); err != nil {
+		d.mu.RUnlock()
+		return err
+	}
+
+	return d.saveLocked(d.mu.RUnlock, nil, true)
+}
+
+// Like DataTankUpdate, but fails with a TankConflictError instead of saving if
+// the tank file changed since it was loaded or last saved by this tank. The
+// change is saved right away even with write-behind.
+func DataTankUpdateIfUnchanged[T any](d *DataTank[T], fn DataTankUpdateFn[T]) error {
+	if err := d.requireRevisions(); err != nil {
+		return err
+	}
+
+	return dataTankUpdate(d, "", true, fn)
+}
+
+// Runs DataTankUpdateIfUnchanged up to attempts times, reloading the tank after
+// each conflict so fn sees the other writer's changes. Returns the last
+// TankConflictError if all attempts conflict.
+func DataTankUpdateRetry[T any](d *DataTank[T], attempts int, fn DataTankUpdateFn[T]) error {
+	for attempt := 1; ; attempt++ {
+		err := DataTankUpdateIfUnchanged(d, fn)
+		if !errors.Is(err, ErrTankConflict) || attempt >= attempts {
+			return err
+		}
+
+		if err := d.Reload(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDataTankRevisions(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithRevisions())
+
+	tank, err := TankStoreOpen[TestData](store, "test-revision")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if revision := tank.Revision(); revision.Number != 0 || revision.Hash != "" {
+		t.Errorf("Expected no revision without a file, got %+v", revision)
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+
+		revision := tank.Revision()
+		if revision.Number != uint64(i) || revision.Hash == "" || revision.ModTime.IsZero() {
+			t.Errorf("Expected revision %d, got %+v", i, revision)
+		}
+	}
+
+	content, _ := store.Storage().Read("test-revision.tank.json")
+	if !bytes.HasPrefix(content, []byte("#ktnuity-tank rev=3\n")) {
+		t.Errorf("Expected revision header, got %q", content)
+	}
+
+	reopened, err := TankStoreOpen[TestData](store, "test-revision")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if reopened.data.Count != 3 || reopened.Revision() != tank.Revision() {
+		t.Errorf("Expected reopened tank at revision 3, got %+v", reopened.Revision())
+	}
+}
+
+func TestDataTankUpdateIfUnchanged(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithRevisions())
+
+	first, err := TankStoreOpen[TestData](store, "test-conflict")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	second, err := TankStoreOpen[TestData](store, "test-conflict")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankUpdateIfUnchanged(first, func(data *TestData) error {
+		data.Items = append(data.Items, "first")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update first DataTank: %v", err)
+	}
+
+	err = DataTankUpdateIfUnchanged(second, func(data *TestData) error {
+		data.Items = append(data.Items, "second")
+		return nil
+	})
+
+	var conflict *TankConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrTankConflict) {
+		t.Fatalf("Expected TankConflictError, got %v", err)
+	}
+
+	if conflict.Expected.Number != 0 || conflict.Actual.Number != 1 {
+		t.Errorf("Expected conflict between revisions 0 and 1, got %+v", conflict)
+	}
+
+	if len(second.data.Items) != 0 {
+		t.Errorf("Expected conflicting update not to be applied, got %v", second.data.Items)
+	}
+
+	if err := second.SaveIfUnchanged(); !errors.Is(err, ErrTankConflict) {
+		t.Errorf("Expected SaveIfUnchanged to conflict, got %v", err)
+	}
+
+	calls := 0
+	err = DataTankUpdateRetry(second, 3, func(data *TestData) error {
+		calls++
+		data.Items = append(data.Items, "second")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update with retry: %v", err)
+	}
+
+	if calls != 2 || len(second.data.Items) != 2 || second.data.Items[0] != "first" {
+		t.Errorf("Expected retry to build on the first writer's change, got %d calls, %v", calls, second.data.Items)
+	}
+
+	if second.Revision().Number != 2 {
+		t.Errorf("Expected revision 2, got %d", second.Revision().Number)
+	}
+
+	// Edits that keep the header are caught by the hash.
+	content, _ := store.Storage().Read("test-conflict.tank.json")
+	store.Storage().Write("test-conflict.tank.json", bytes.Replace(content, []byte("second"), []byte("edited"), 1))
+
+	if err := second.SaveIfUnchanged(); !errors.Is(err, ErrTankConflict) {
+		t.Errorf("Expected SaveIfUnchanged to detect the edit, got %v", err)
+	}
+
+	if err := second.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	if second.Revision().Number != 3 {
+		t.Errorf("Expected unconditional save to move on to revision 3, got %d", second.Revision().Number)
+	}
+}
+
+func TestDataTankRetryGivesUp(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithRevisions())
+
+	tank, err := TankStoreOpen[TestData](store, "test-retry")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	other, err := TankStoreOpen[TestData](store, "test-retry")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	// Another writer gets in before every attempt.
+	err = DataTankUpdateRetry(tank, 2, func(data *TestData) error {
+		return DataTankSet(other, func(data *TestData) {
+			data.Count++
+		})
+	})
+	if !errors.Is(err, ErrTankConflict) {
+		t.Errorf("Expected ErrTankConflict after all attempts, got %v", err)
+	}
+
+	plain, err := TankStoreOpen[TestData](TankStoreWithStorage(TankStorageMemory()), "test-plain")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if err := plain.SaveIfUnchanged(); err == nil {
+		t.Error("Expected SaveIfUnchanged to require revisions")
+	}
+}
+
+func TestDataTankConflictKeepsHistory(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithRevisions(), DataTankWithHistory(TankHistoryPolicy{}))
+
+	first, err := TankStoreOpen[TestData](store, "test-conflict-history")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	second, err := TankStoreOpen[TestData](store, "test-conflict-history")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		err = DataTankSet(first, func(data *TestData) {
+			data.Count = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	err = DataTankUpdateIfUnchanged(second, func(data *TestData) error {
+		data.Count = 100
+		return nil
+	})
+	if !errors.Is(err, ErrTankConflict) {
+		t.Fatalf("Expected TankConflictError, got %v", err)
+	}
+
+	// The rejected save must not have replaced the history file with its own.
+	reopened, err := TankStoreOpen[TestData](store, "test-conflict-history")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if history := reopened.History(); len(history.Undo) != 2 {
+		t.Fatalf("Expected the first writer's 2 undo entries, got %+v", history)
+	}
+
+	if err := reopened.Undo(); err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+
+	if reopened.data.Count != 1 {
+		t.Errorf("Expected undo to go back to Count 1, got %d", reopened.data.Count)
+	}
+}
@@ -0,0 +1,162 @@
+package ktnuitygo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TankMigrationFn upgrades a decoded tank document by one schema version. With
+// the JSON codecs numbers are json.Number, so large integers stay exact.
+type TankMigrationFn func(doc map[string]any) (map[string]any, error)
+
+type tankEnvelope[T any] struct {
+	Schema int `json:"_schema" yaml:"_schema" toml:"_schema"`
+	Data   *T  `json:"_data" yaml:"_data" toml:"_data"`
+}
+
+// Only the version, to tell up to date files apart without decoding them twice.
+type tankSchemaHeader struct {
+	Schema *int `json:"_schema" yaml:"_schema" toml:"_schema"`
+}
+
+const (
+	tankSchemaKey = "_schema"
+	tankDataKey   = "_data"
+)
+
+// Stores the tank in a versioned envelope. migrations[i] upgrades a document
+// from schema version i to i+1, files without an envelope are version 0. The
+// codec must be able to decode into a map[string]any, so gob isn't supported.
+func DataTankWithMigrations(migrations ...TankMigrationFn) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.migrations = append(make([]TankMigrationFn, 0, len(migrations)), migrations...)
+	}
+}
+
+func (d *DataTank[T]) decodeSchema(content []byte, target *T) (bool, error) {
+	codec := d.config.codec
+	format := strings.ToUpper(codec.Extension())
+	current := len(d.config.migrations)
+
+	// Up to date files decode straight into T, like they would without migrations.
+	var header tankSchemaHeader
+	if err := codec.Decode(content, &header); err == nil && header.Schema != nil && *header.Schema == current {
+		if err := codec.Decode(content, &tankEnvelope[T]{Data: target}); err != nil {
+			return false, d.corruption(TankDecodeFailed, fmt.Errorf("failed to decode %s: %v", format, err))
+		}
+
+		return false, nil
+	}
+
+	doc, err := decodeSchemaDoc(codec, content)
+	if err != nil {
+		return false, d.corruption(TankDecodeFailed, fmt.Errorf("failed to decode %s: %v", format, err))
+	}
+
+	version, data, err := unwrapTankEnvelope(doc)
+	if err != nil {
+		return false, err
+	}
+
+	_, enveloped := doc[tankSchemaKey]
+	dirty := !enveloped || version != current
+
+	if version > current {
+		return false, fmt.Errorf("tank schema version %d is newer than supported version %d", version, current)
+	}
+
+	for ; version < current; version++ {
+		data, err = d.config.migrations[version](data)
+		if err != nil {
+			return false, fmt.Errorf("failed to migrate tank schema from version %d: %v", version, err)
+		}
+
+		if data == nil {
+			data = make(map[string]any)
+		}
+	}
+
+	// The migrated document goes through the codec again so T is decoded with
+	// the exact same rules as an up to date file.
+	content, err = codec.Encode(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode migrated %s: %v", format, err)
+	}
+
+	if err := codec.Decode(content, target); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %v", format, err)
+	}
+
+	return dirty, nil
+}
+
+// encoding/json would turn numbers into float64, losing integers above 2^53.
+func decodeSchemaDoc(codec TankCodec, content []byte) (map[string]any, error) {
+	if _, ok := codec.(jsonCodec); !ok {
+		var doc map[string]any
+		err := codec.Decode(content, &doc)
+		return doc, err
+	}
+
+	doc, err := decodeJsonDoc(content)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+
+	object, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", doc)
+	}
+
+	return object, nil
+}
+
+func unwrapTankEnvelope(doc map[string]any) (int, map[string]any, error) {
+	if doc == nil {
+		return 0, make(map[string]any), nil
+	}
+
+	raw, enveloped := doc[tankSchemaKey]
+	if !enveloped {
+		return 0, doc, nil
+	}
+
+	version := toInt64(raw)
+	if version < 0 {
+		return 0, nil, fmt.Errorf("invalid tank schema version '%v'", raw)
+	}
+
+	data, ok := doc[tankDataKey].(map[string]any)
+	if !ok && doc[tankDataKey] != nil {
+		return 0, nil, fmt.Errorf("invalid tank data of type %T", doc[tankDataKey])
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+
+	return int(version), data, nil
+}
+
+// Codecs disagree on how numbers decode into any, -1 means not a number.
+func toInt64(value any) int64 {
+	switch number := value.(type) {
+	case int:
+		return int64(number)
+	case int64:
+		return number
+	case uint64:
+		return int64(number)
+	case float64:
+		if number == float64(int64(number)) {
+			return int64(number)
+		}
+	case json.Number:
+		if parsed, err := number.Int64(); err == nil {
+			return parsed
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,161 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+type TestDataSchema struct {
+	FullName string
+	Count    int
+	Tags     []string
+}
+
+func testSchemaMigrations() []TankMigrationFn {
+	return []TankMigrationFn{
+		func(doc map[string]any) (map[string]any, error) {
+			doc["FullName"] = doc["Name"]
+			delete(doc, "Name")
+			return doc, nil
+		},
+		func(doc map[string]any) (map[string]any, error) {
+			if tag, ok := doc["Tag"].(string); ok {
+				doc["Tags"] = []any{tag}
+			}
+			delete(doc, "Tag")
+			return doc, nil
+		},
+	}
+}
+
+func TestDataTankMigrateLegacyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := TankStoreNew(tmpDir)
+
+	err := os.WriteFile(tmpDir+"/legacy.tank.json", []byte(`{"Name": "Alice", "Count": 3, "Tag": "admin"}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create legacy file: %v", err)
+	}
+
+	tank, err := TankStoreOpen[TestDataSchema](store, "legacy", DataTankWithMigrations(testSchemaMigrations()...))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	data := tank.data
+	if data.FullName != "Alice" || data.Count != 3 || len(data.Tags) != 1 || data.Tags[0] != "admin" {
+		t.Errorf("Expected migrated data, got %+v", *data)
+	}
+
+	content, err := os.ReadFile(tmpDir + "/legacy.tank.json")
+	if err != nil {
+		t.Fatalf("Failed to read tank file: %v", err)
+	}
+
+	if !strings.Contains(string(content), `"_schema": 2`) {
+		t.Errorf("Expected upgraded file to be persisted with schema 2, got '%s'", content)
+	}
+}
+
+func TestDataTankMigratePartial(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+	storage := store.Storage()
+
+	err := storage.Write("partial.tank.json", []byte(`{"_schema": 1, "_data": {"FullName": "Bob", "Tag": "user"}}`))
+	if err != nil {
+		t.Fatalf("Failed to write tank file: %v", err)
+	}
+
+	calls := 0
+	migrations := testSchemaMigrations()
+	first := migrations[0]
+	migrations[0] = func(doc map[string]any) (map[string]any, error) {
+		calls++
+		return first(doc)
+	}
+
+	tank, err := TankStoreOpen[TestDataSchema](store, "partial", DataTankWithMigrations(migrations...))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("Expected migrations below the stored version to be skipped, got %d calls", calls)
+	}
+
+	if tank.data.FullName != "Bob" || len(tank.data.Tags) != 1 {
+		t.Errorf("Expected migrated data, got %+v", *tank.data)
+	}
+}
+
+func TestDataTankMigrateErrors(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+	storage := store.Storage()
+
+	storage.Write("newer.tank.json", []byte(`{"_schema": 5, "_data": {}}`))
+	_, err := TankStoreOpen[TestDataSchema](store, "newer", DataTankWithMigrations(testSchemaMigrations()...))
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("Expected newer schema error, got %v", err)
+	}
+
+	storage.Write("failing.tank.json", []byte(`{}`))
+	_, err = TankStoreOpen[TestDataSchema](store, "failing", DataTankWithMigrations(func(doc map[string]any) (map[string]any, error) {
+		return nil, errors.New("boom")
+	}))
+	if err == nil || !strings.Contains(err.Error(), "failed to migrate tank schema from version 0") {
+		t.Errorf("Expected migration error, got %v", err)
+	}
+}
+
+func TestDataTankMigrateLargeIntegers(t *testing.T) {
+	const id int64 = 9007199254740993
+
+	store := TankStoreWithStorage(TankStorageMemory())
+	storage := store.Storage()
+
+	migrations := []TankMigrationFn{
+		func(doc map[string]any) (map[string]any, error) {
+			doc["Name"] = doc["Nick"]
+			delete(doc, "Nick")
+			return doc, nil
+		},
+	}
+
+	files := map[string]string{
+		"current": `{"_schema": 1, "_data": {"ID": 9007199254740993, "Name": "x"}}`,
+		"legacy":  `{"ID": 9007199254740993, "Nick": "x", "Owners": {"a": 18446744073709551615}}`,
+	}
+
+	for name, content := range files {
+		storage.Write(name+".tank.json", []byte(content))
+
+		tank, err := TankStoreOpen[TestDataSnowflake](store, name, DataTankWithMigrations(migrations...))
+		if err != nil {
+			t.Fatalf("Failed to open DataTank '%s': %v", name, err)
+		}
+
+		if tank.data.ID != id || tank.data.Name != "x" {
+			t.Errorf("Expected '%s' to load ID %d, got %+v", name, id, *tank.data)
+		}
+
+		if err := tank.Save(); err != nil {
+			t.Fatalf("Failed to save DataTank '%s': %v", name, err)
+		}
+
+		saved, _ := storage.Read(name + ".tank.json")
+		if !strings.Contains(string(saved), "9007199254740993") {
+			t.Errorf("Expected '%s' to be saved with the exact ID, got '%s'", name, saved)
+		}
+	}
+
+	legacy, err := TankStoreOpen[TestDataSnowflake](store, "legacy", DataTankWithMigrations(migrations...))
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if legacy.data.Owners["a"] != 18446744073709551615 {
+		t.Errorf("Expected the migrated uint64 to stay exact, got %d", legacy.data.Owners["a"])
+	}
+}
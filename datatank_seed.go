@@ -0,0 +1,93 @@
+package ktnuitygo
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// TankSeed provides the data a tank starts out with when its file is missing,
+// instead of the zero value.
+type TankSeed struct {
+	fill func(target any, codec TankCodec) error
+}
+
+type TankSeedPolicy struct {
+	// Saves the seed as soon as the tank is opened without a file, rather than
+	// with the first change.
+	Write bool
+	// Decodes existing tank files on top of the seed, so members missing from
+	// the file keep their seeded values. Members set to null in the file stay
+	// null, and maps are merged with the seeded ones.
+	Overlay bool
+}
+
+type tankSeedConfig struct {
+	seed   TankSeed
+	policy TankSeedPolicy
+}
+
+// Seeds the tank from what fn returns, which has to be the tank's T.
+func TankSeedFunc[T any](fn func() T) TankSeed {
+	return TankSeed{
+		fill: func(target any, codec TankCodec) error {
+			data, ok := target.(*T)
+			if !ok {
+				return fmt.Errorf("seed of type %T does not match tank data of type %T", new(T), target)
+			}
+
+			*data = fn()
+			return nil
+		},
+	}
+}
+
+// Seeds the tank from content, decoded with the tank's codec.
+func TankSeedBytes(content []byte) TankSeed {
+	return TankSeed{
+		fill: func(target any, codec TankCodec) error {
+			return codec.Decode(content, target)
+		},
+	}
+}
+
+// Seeds the tank from the file name in fsys, e.g. an embed.FS, decoded with the
+// tank's codec. The file is read whenever the seed is used.
+func TankSeedFile(fsys fs.FS, name string) TankSeed {
+	return TankSeed{
+		fill: func(target any, codec TankCodec) error {
+			content, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+
+			return codec.Decode(content, target)
+		},
+	}
+}
+
+func DataTankWithSeed(seed TankSeed, policy TankSeedPolicy) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.seed = &tankSeedConfig{
+			seed:   seed,
+			policy: policy,
+		}
+	}
+}
+
+// Fills data from the seed if there is one and it applies. missing tells
+// whether the tank file exists, returns whether the seed should be saved.
+func (d *DataTank[T]) seed(data *T, missing bool) (bool, *TankLoadError) {
+	if d.config.seed == nil || !(missing || d.config.seed.policy.Overlay) {
+		return false, nil
+	}
+
+	if err := d.config.seed.seed.fill(data, d.config.codec); err != nil {
+		return false, &TankLoadError{
+			isSafe: false,
+			message: fmt.Sprintf("failed to seed '%s': %v", d.fileName(), err),
+			err: err,
+		}
+	}
+
+	return missing && d.config.seed.policy.Write, nil
+}
@@ -0,0 +1,108 @@
+package ktnuitygo
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestDataTankSeed(t *testing.T) {
+	seeds := map[string]TankSeed{
+		"func": TankSeedFunc(func() TestData {
+			return TestData{Name: "seeded", Count: 3}
+		}),
+		"bytes": TankSeedBytes([]byte(`{"Name":"seeded","Count":3}`)),
+		"file": TankSeedFile(fstest.MapFS{
+			"seed.json": {Data: []byte(`{"Name":"seeded","Count":3}`)},
+		}, "seed.json"),
+	}
+
+	for kind, seed := range seeds {
+		store := TankStoreWithStorage(TankStorageMemory())
+
+		tank, err := TankStoreOpen[TestData](store, "test-seed", DataTankWithSeed(seed, TankSeedPolicy{}))
+		if err != nil {
+			t.Fatalf("Failed to create DataTank with %s seed: %v", kind, err)
+		}
+
+		if tank.data.Name != "seeded" || tank.data.Count != 3 || tank.data.Meta == nil {
+			t.Errorf("Expected %s seed to be used, got %+v", kind, *tank.data)
+		}
+
+		if exists, _ := store.Exists("test-seed"); exists {
+			t.Errorf("Expected %s seed not to be written without Write", kind)
+		}
+	}
+}
+
+func TestDataTankSeedWrite(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+	seed := TankSeedBytes([]byte(`{"Name":"seeded"}`))
+
+	_, err := TankStoreOpen[TestData](store, "test-seed", DataTankWithSeed(seed, TankSeedPolicy{Write: true}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	content, err := store.Storage().Read("test-seed.tank.json")
+	if err != nil {
+		t.Fatalf("Expected seed to be written: %v", err)
+	}
+
+	reopened, err := TankStoreOpen[TestData](store, "test-seed")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if reopened.data.Name != "seeded" {
+		t.Errorf("Expected written seed to load without the option, got %s", content)
+	}
+}
+
+func TestDataTankSeedOverlay(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+	store.Storage().Write("test-seed.tank.json", []byte(`{"Name":"existing","Meta":{"own":1}}`))
+
+	seed := TankSeedFunc(func() TestData {
+		return TestData{Name: "seeded", Count: 3, Meta: map[string]int{"seeded": 2}}
+	})
+
+	tank, err := TankStoreOpen[TestData](store, "test-seed", DataTankWithSeed(seed, TankSeedPolicy{}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if tank.data.Count != 0 {
+		t.Errorf("Expected seed to be ignored for existing files, got %+v", *tank.data)
+	}
+
+	overlaid, err := TankStoreOpen[TestData](store, "test-seed", DataTankWithSeed(seed, TankSeedPolicy{Overlay: true}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if overlaid.data.Name != "existing" || overlaid.data.Count != 3 {
+		t.Errorf("Expected absent members to come from the seed, got %+v", *overlaid.data)
+	}
+
+	if overlaid.data.Meta["own"] != 1 || overlaid.data.Meta["seeded"] != 2 {
+		t.Errorf("Expected maps to be merged, got %v", overlaid.data.Meta)
+	}
+}
+
+func TestDataTankSeedErrors(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	seeds := []TankSeed{
+		TankSeedBytes([]byte(`not json`)),
+		TankSeedFile(fstest.MapFS{}, "missing.json"),
+		TankSeedFunc(func() TestUser {
+			return TestUser{}
+		}),
+	}
+
+	for i, seed := range seeds {
+		if _, err := TankStoreOpen[TestData](store, "test-seed", DataTankWithSeed(seed, TankSeedPolicy{})); err == nil {
+			t.Errorf("Expected seed %d to fail", i)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ktnuitygo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Returns a deep copy of the tank data, safe to keep and use from any
+// goroutine. Changing it has no effect on the tank.
+func (d *DataTank[T]) Snapshot() T {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return *deepCopy(d.data)
+}
+
+// Like DataTankGet, but returns a deep copy of what fn returns instead of a
+// pointer that may reach into the live tank data.
+func DataTankSnapshot[R any, T any](d *DataTank[T], fn DataTankGetFn[R, T]) *R {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := fn(d.data)
+	if result == nil {
+		return nil
+	}
+
+	return deepCopy(result)
+}
+
+// Reported when the tank data changed without going through DataTankSet,
+// DataTankUpdate or the like, e.g. through a pointer kept from DataTankGet.
+type TankMutationError struct {
+	Name string
+}
+
+func (err *TankMutationError) Error() string {
+	return fmt.Sprintf("DataTank '%s' data was mutated outside of DataTankSet or DataTankUpdate", err.Name)
+}
+
+// Debug mode that keeps a copy of the data as of the last change and compares
+// it before the next one. Differences are passed to report as TankMutationError.
+// Values that never compare equal, such as funcs and NaN, show up as mutations.
+func DataTankWithMutationCheck(report ErrorConsumerFn) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.mutationCheck = report
+	}
+}
+
+type tankMutationCheck[T any] struct {
+	mu       sync.Mutex
+	baseline *T
+}
+
+// Must be called with mu held, at least for reading.
+func (d *DataTank[T]) recordBaseline() {
+	if d.config.mutationCheck == nil {
+		return
+	}
+
+	copied := deepCopy(d.data)
+
+	d.mutations.mu.Lock()
+	d.mutations.baseline = copied
+	d.mutations.mu.Unlock()
+}
+
+// Must be called with mu held, at least for reading, before the data is changed.
+func (d *DataTank[T]) checkMutations() {
+	if d.config.mutationCheck == nil {
+		return
+	}
+
+	d.mutations.mu.Lock()
+	mutated := d.mutations.baseline != nil && !reflect.DeepEqual(*d.mutations.baseline, *d.data)
+	d.mutations.mu.Unlock()
+
+	if mutated {
+		d.config.mutationCheck(&TankMutationError{Name: d.name})
+		d.recordBaseline()
+	}
+}
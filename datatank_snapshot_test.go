@@ -0,0 +1,104 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestDataTankSnapshot(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](store, "test-snapshot")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Items = append(data.Items, "a")
+		data.Meta["x"] = 1
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	snapshot := tank.Snapshot()
+	snapshot.Items[0] = "changed"
+	snapshot.Meta["x"] = 2
+
+	if tank.data.Items[0] != "a" || tank.data.Meta["x"] != 1 {
+		t.Errorf("Expected snapshot changes not to reach the tank, got %+v", *tank.data)
+	}
+
+	meta := DataTankSnapshot(tank, func(data *TestData) *map[string]int {
+		return &data.Meta
+	})
+	(*meta)["y"] = 3
+
+	if _, exists := tank.data.Meta["y"]; exists {
+		t.Error("Expected projected snapshot changes not to reach the tank")
+	}
+
+	missing := DataTankSnapshot(tank, func(data *TestData) *string {
+		return nil
+	})
+	if missing != nil {
+		t.Errorf("Expected nil projection to stay nil, got %v", missing)
+	}
+}
+
+func TestDataTankMutationCheck(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	var mu sync.Mutex
+	var reported []error
+	tank, err := TankStoreOpen[TestData](store, "test-mutation", DataTankWithMutationCheck(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 1
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if len(reported) != 0 {
+		t.Fatalf("Expected no reports for regular changes, got %v", reported)
+	}
+
+	leaked := DataTankGet(tank, func(data *TestData) *map[string]int {
+		return &data.Meta
+	})
+	(*leaked)["sneaky"] = 1
+
+	if err := tank.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	if len(reported) != 1 {
+		t.Fatalf("Expected 1 report, got %d: %v", len(reported), reported)
+	}
+
+	var mutationErr *TankMutationError
+	if !errors.As(reported[0], &mutationErr) || mutationErr.Name != "test-mutation" {
+		t.Errorf("Expected TankMutationError for 'test-mutation', got %v", reported[0])
+	}
+
+	err = DataTankUpdate(tank, func(data *TestData) error {
+		data.Count = 2
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update DataTank data: %v", err)
+	}
+
+	if len(reported) != 1 {
+		t.Errorf("Expected the mutation to be reported once, got %d reports", len(reported))
+	}
+}
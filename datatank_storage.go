@@ -0,0 +1,399 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TankFileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// TankStorage is where DataTank files live. Names are plain file names such as
+// 'name.tank.json'. Read and Stat wrap fs.ErrNotExist for missing files.
+type TankStorage interface {
+	Read(name string) ([]byte, error)
+	Write(name string, data []byte) error
+	Delete(name string) error
+	List() ([]TankFileInfo, error)
+	Stat(name string) (TankFileInfo, error)
+}
+
+// TankStorageLocker is implemented by storages that can hold an exclusive lock
+// across everyone sharing them, as required by DataTankWithFileLock.
+type TankStorageLocker interface {
+	Lock(name string) (func(), error)
+}
+
+// TankStorageSub is implemented by storages that can nest another storage under
+// them, as used by DataTankCollection. Sub returns the same files for the same dir.
+type TankStorageSub interface {
+	Sub(dir string) (TankStorage, error)
+}
+
+var ErrTankStorageReadOnly = errors.New("tank storage is read-only")
+
+type fileTankStorage struct {
+	dir string
+}
+
+func TankStorageDir(dir string) TankStorage {
+	return &fileTankStorage{
+		dir: dir,
+	}
+}
+
+func (s *fileTankStorage) path(name string) string {
+	return fmt.Sprintf("%s/%s", s.dir, name)
+}
+
+func (s *fileTankStorage) Read(name string) ([]byte, error) {
+	return os.ReadFile(s.path(name))
+}
+
+func (s *fileTankStorage) Write(name string, data []byte) error {
+	return writeFileAtomic(s.path(name), data)
+}
+
+func (s *fileTankStorage) Delete(name string) error {
+	return os.Remove(s.path(name))
+}
+
+func (s *fileTankStorage) List() ([]TankFileInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TankFileInfo, 0, len(entries))
+	for _, entry := range entries {
+		// Skips directories and in-flight temp files from writeFileAtomic.
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		result = append(result, fileInfo(info))
+	}
+
+	return result, nil
+}
+
+func (s *fileTankStorage) Stat(name string) (TankFileInfo, error) {
+	info, err := os.Stat(s.path(name))
+	if err != nil {
+		return TankFileInfo{}, err
+	}
+
+	return fileInfo(info), nil
+}
+
+func (s *fileTankStorage) Append(name string, data []byte) error {
+	file, err := os.OpenFile(s.path(name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+func (s *fileTankStorage) Sub(dir string) (TankStorage, error) {
+	if err := os.MkdirAll(s.path(dir), 0755); err != nil {
+		return nil, err
+	}
+
+	return TankStorageDir(s.path(dir)), nil
+}
+
+func (s *fileTankStorage) Lock(name string) (func(), error) {
+	return lockFile(s.path(name))
+}
+
+func fileInfo(info fs.FileInfo) TankFileInfo {
+	return TankFileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+}
+
+// Writes to a temp file next to filename and renames it over the original, so
+// the file on disk is always either the previous or the new complete version.
+func writeFileAtomic(filename string, data []byte) (err error) {
+	dir := filepath.Dir(filename)
+
+	var mode os.FileMode = 0644
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for '%s': %w", filename, err)
+	}
+
+	tmpName := file.Name()
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = file.Write(data); err != nil {
+		return fmt.Errorf("failed to write temp file '%s': %w", tmpName, err)
+	}
+
+	if err = file.Chmod(mode); err != nil {
+		return fmt.Errorf("failed to chmod temp file '%s': %w", tmpName, err)
+	}
+
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temp file '%s': %w", tmpName, err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file '%s': %w", tmpName, err)
+	}
+
+	if err = os.Rename(tmpName, filename); err != nil {
+		return fmt.Errorf("failed to replace file '%s': %w", filename, err)
+	}
+
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	// Directories can't be opened for syncing on windows.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	file, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory '%s': %w", dir, err)
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory '%s': %w", dir, err)
+	}
+
+	return nil
+}
+
+type memoryTankFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+type memoryTankStorage struct {
+	mu    sync.RWMutex
+	files map[string]memoryTankFile
+	locks map[string]*sync.Mutex
+	subs  map[string]*memoryTankStorage
+}
+
+// Keeps tank files in memory, mostly useful for tests.
+func TankStorageMemory() TankStorage {
+	return &memoryTankStorage{
+		files: make(map[string]memoryTankFile),
+		locks: make(map[string]*sync.Mutex),
+		subs:  make(map[string]*memoryTankStorage),
+	}
+}
+
+func (s *memoryTankStorage) Read(name string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, exists := s.files[name]
+	if !exists {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return slices.Clone(file.data), nil
+}
+
+func (s *memoryTankStorage) Write(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[name] = memoryTankFile{
+		data:    slices.Clone(data),
+		modTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (s *memoryTankStorage) Append(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := s.files[name]
+	s.files[name] = memoryTankFile{
+		data:    append(slices.Clone(file.data), data...),
+		modTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (s *memoryTankStorage) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.files[name]; !exists {
+		return &fs.PathError{Op: "delete", Path: name, Err: fs.ErrNotExist}
+	}
+
+	delete(s.files, name)
+	return nil
+}
+
+func (s *memoryTankStorage) List() ([]TankFileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]TankFileInfo, 0, len(s.files))
+	for name, file := range s.files {
+		result = append(result, file.info(name))
+	}
+
+	slices.SortFunc(result, func(a, b TankFileInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result, nil
+}
+
+func (s *memoryTankStorage) Stat(name string) (TankFileInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, exists := s.files[name]
+	if !exists {
+		return TankFileInfo{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return file.info(name), nil
+}
+
+func (s *memoryTankStorage) Lock(name string) (func(), error) {
+	s.mu.Lock()
+	lock, exists := s.locks[name]
+	if !exists {
+		lock = &sync.Mutex{}
+		s.locks[name] = lock
+	}
+	s.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock, nil
+}
+
+func (s *memoryTankStorage) Sub(dir string) (TankStorage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, exists := s.subs[dir]
+	if !exists {
+		sub = TankStorageMemory().(*memoryTankStorage)
+		s.subs[dir] = sub
+	}
+
+	return sub, nil
+}
+
+func (f memoryTankFile) info(name string) TankFileInfo {
+	return TankFileInfo{
+		Name:    name,
+		Size:    int64(len(f.data)),
+		ModTime: f.modTime,
+	}
+}
+
+type fsTankStorage struct {
+	fsys fs.FS
+}
+
+// Serves tank files from fsys, e.g. an embed.FS. Writes and deletes fail with
+// ErrTankStorageReadOnly.
+func TankStorageFS(fsys fs.FS) TankStorage {
+	return &fsTankStorage{
+		fsys: fsys,
+	}
+}
+
+func (s *fsTankStorage) Read(name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, name)
+}
+
+func (s *fsTankStorage) Write(name string, data []byte) error {
+	return fmt.Errorf("failed to write '%s': %w", name, ErrTankStorageReadOnly)
+}
+
+func (s *fsTankStorage) Delete(name string) error {
+	return fmt.Errorf("failed to delete '%s': %w", name, ErrTankStorageReadOnly)
+}
+
+func (s *fsTankStorage) List() ([]TankFileInfo, error) {
+	entries, err := fs.ReadDir(s.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TankFileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		result = append(result, fileInfo(info))
+	}
+
+	return result, nil
+}
+
+func (s *fsTankStorage) Sub(dir string) (TankStorage, error) {
+	fsys, err := fs.Sub(s.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return TankStorageFS(fsys), nil
+}
+
+func (s *fsTankStorage) Stat(name string) (TankFileInfo, error) {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return TankFileInfo{}, err
+	}
+
+	return fileInfo(info), nil
+}
@@ -0,0 +1,111 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTankStorageMemory(t *testing.T) {
+	storage := TankStorageMemory()
+
+	tank, err := DataTankNew[TestData]("test-memory", DataTankWithStorage(storage))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Name = "Alice"
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	info, err := storage.Stat("test-memory.tank.json")
+	if err != nil {
+		t.Fatalf("Failed to stat tank file: %v", err)
+	}
+
+	if info.Size == 0 || info.ModTime.IsZero() {
+		t.Errorf("Expected size and modification time to be set, got %+v", info)
+	}
+
+	reloaded, err := DataTankNew[TestData]("test-memory", DataTankWithStorage(storage))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if reloaded.data.Name != "Alice" {
+		t.Errorf("Expected Name to be 'Alice', got '%s'", reloaded.data.Name)
+	}
+
+	files, err := storage.List()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected a single file, got %v (%v)", files, err)
+	}
+
+	if err := storage.Delete("test-memory.tank.json"); err != nil {
+		t.Fatalf("Failed to delete tank file: %v", err)
+	}
+
+	if _, err := storage.Read("test-memory.tank.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist after delete, got %v", err)
+	}
+}
+
+func TestTankStorageDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := TankStorageDir(tmpDir)
+
+	if err := storage.Write("a.tank.json", []byte("{}")); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	content, err := storage.Read("a.tank.json")
+	if err != nil || string(content) != "{}" {
+		t.Fatalf("Expected '{}', got '%s' (%v)", content, err)
+	}
+
+	files, err := storage.List()
+	if err != nil || len(files) != 1 || files[0].Name != "a.tank.json" || files[0].Size != 2 {
+		t.Fatalf("Expected a single 2 byte file, got %v (%v)", files, err)
+	}
+
+	if err := storage.Delete("a.tank.json"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	if _, err := storage.Stat("a.tank.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist after delete, got %v", err)
+	}
+}
+
+func TestTankStorageFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"seed.tank.json": &fstest.MapFile{Data: []byte(`{"Name": "Seed", "Count": 3}`)},
+	}
+
+	storage := TankStorageFS(fsys)
+
+	tank, err := DataTankNew[TestData]("seed", DataTankWithStorage(storage))
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if tank.data.Name != "Seed" || tank.data.Count != 3 {
+		t.Errorf("Expected data from fs.FS, got %+v", *tank.data)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count++
+	})
+	if !errors.Is(err, ErrTankStorageReadOnly) {
+		t.Errorf("Expected ErrTankStorageReadOnly, got %v", err)
+	}
+
+	files, err := storage.List()
+	if err != nil || len(files) != 1 {
+		t.Errorf("Expected a single file, got %v (%v)", files, err)
+	}
+}
@@ -0,0 +1,92 @@
+package ktnuitygo
+
+import "fmt"
+
+// TankStore opens DataTanks from its own storage with a shared set of options,
+// so independent users of this package don't have to share DataTankSetDir.
+type TankStore struct {
+	dir     string
+	storage TankStorage
+	options []DataTankOption
+}
+
+func TankStoreNew(dir string, options ...DataTankOption) *TankStore {
+	for len(dir) > 0 && dir[len(dir)-1] == '/' {
+		dir = dir[:len(dir)-1]
+	}
+
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	return &TankStore{
+		dir:     dir,
+		storage: TankStorageDir(dir),
+		options: options,
+	}
+}
+
+func TankStoreWithStorage(storage TankStorage, options ...DataTankOption) *TankStore {
+	return &TankStore{
+		storage: storage,
+		options: options,
+	}
+}
+
+// Dir is empty for stores that aren't backed by a directory.
+func (s *TankStore) Dir() string {
+	return s.dir
+}
+
+func (s *TankStore) Storage() TankStorage {
+	return s.storage
+}
+
+// The config tanks opened from the store start out with.
+func (s *TankStore) config() dataTankConfig {
+	config := dataTankConfig{
+		codec:   TankCodecJSON,
+		storage: s.storage,
+	}
+
+	for _, option := range s.options {
+		option(&config)
+	}
+
+	return config
+}
+
+// Opens the tank name with the store's options, followed by options.
+func TankStoreOpen[T any](store *TankStore, name string, options ...DataTankOption) (*DataTank[T], error) {
+	if err := validateTankName(name); err != nil {
+		return nil, fmt.Errorf("failed to open DataTank: %w", err)
+	}
+
+	tank := &DataTank[T]{
+		name:   name,
+		config: store.config(),
+	}
+
+	for _, option := range options {
+		option(&tank.config)
+	}
+
+	data, info, err := tank.load(false)
+	if err != nil && !err.isSafe {
+		return nil, fmt.Errorf("failed to load DataTank '%s' data: %w", name, err)
+	}
+
+	tank.setData(data, info)
+
+	if err := tank.loadHistory(); err != nil {
+		return nil, err
+	}
+
+	if info.dirty {
+		if err := tank.Save(); err != nil {
+			return nil, fmt.Errorf("failed to save DataTank '%s' data: %w", name, err)
+		}
+	}
+
+	return tank, nil
+}
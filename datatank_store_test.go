@@ -0,0 +1,74 @@
+package ktnuitygo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTankStoreOpen(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	store := TankStoreNew(tmpDir+"/", DataTankWithCodec(TankCodecYAML))
+
+	if store.Dir() != tmpDir {
+		t.Errorf("Expected store dir to be '%s', got '%s'", tmpDir, store.Dir())
+	}
+
+	tank, err := TankStoreOpen[TestData](store, "test-store")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Name = "Alice"
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if _, err := os.Stat(tmpDir + "/test-store.tank.yaml"); err != nil {
+		t.Errorf("Expected store codec to be used: %v", err)
+	}
+
+	override, err := TankStoreOpen[TestData](store, "test-store", DataTankWithCodec(TankCodecJSON))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if override.data.Name != "" {
+		t.Errorf("Expected per-tank codec to override the store codec, got Name '%s'", override.data.Name)
+	}
+}
+
+func TestTankStoreIsolation(t *testing.T) {
+	t.Parallel()
+
+	first := TankStoreWithStorage(TankStorageMemory())
+	second := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[TestData](first, "shared-name")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 7
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	other, err := TankStoreOpen[TestData](second, "shared-name")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if other.data.Count != 0 {
+		t.Errorf("Expected stores to be isolated, got Count %d", other.data.Count)
+	}
+
+	if second.Dir() != "" {
+		t.Errorf("Expected storage-backed store to have no dir, got '%s'", second.Dir())
+	}
+}
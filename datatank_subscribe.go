@@ -0,0 +1,150 @@
+package ktnuitygo
+
+import "sync"
+
+type DataTankChange[T any] struct {
+	// Deep copies, safe to keep and read from any goroutine.
+	Previous *T
+	Current  *T
+	// Changes dropped from a full channel right before this one was queued.
+	Dropped int
+}
+
+type DataTankSubscribeFn[T any] func(change DataTankChange[T])
+
+type tankSubscribers[T any] struct {
+	mu      sync.Mutex
+	next    int
+	entries map[int]*tankSubscriber[T]
+}
+
+type tankSubscriber[T any] struct {
+	fn      DataTankSubscribeFn[T]
+	channel chan DataTankChange[T]
+}
+
+// Calls fn after every successful DataTankSet, DataTankUpdate, Reload and
+// Restore, in the order the changes were persisted. fn runs on the goroutine
+// that made the change and must not mutate this tank, use SubscribeChan for
+// that. The returned function unsubscribes.
+func (d *DataTank[T]) Subscribe(fn DataTankSubscribeFn[T]) func() {
+	return d.subscribers.add(&tankSubscriber[T]{fn: fn})
+}
+
+// Delivers changes on a channel holding up to buffer of them. When the channel
+// is full the oldest queued change is dropped in favor of the new one, and the
+// new one's Dropped is incremented. The returned function unsubscribes and
+// closes the channel.
+func (d *DataTank[T]) SubscribeChan(buffer int) (<-chan DataTankChange[T], func()) {
+	subscriber := &tankSubscriber[T]{
+		channel: make(chan DataTankChange[T], max(buffer, 1)),
+	}
+
+	return subscriber.channel, d.subscribers.add(subscriber)
+}
+
+func (s *tankSubscribers[T]) add(subscriber *tankSubscriber[T]) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = make(map[int]*tankSubscriber[T])
+	}
+
+	id := s.next
+	s.next++
+	s.entries[id] = subscriber
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			delete(s.entries, id)
+			if subscriber.channel != nil {
+				close(subscriber.channel)
+			}
+		})
+	}
+}
+
+func (s *tankSubscribers[T]) any() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries) > 0
+}
+
+// Must be called with mu held. Returns nil when nobody is subscribed, so
+// changes only pay for the copy when someone is listening.
+func (d *DataTank[T]) copyForChange() *T {
+	if !d.subscribers.any() {
+		return nil
+	}
+
+	return deepCopy(d.data)
+}
+
+// Must be called with mu held, after the change. previous must no longer be
+// reachable from the tank.
+func (d *DataTank[T]) changeNotifier(previous *T) func() {
+	if previous == nil || !d.subscribers.any() {
+		return nil
+	}
+
+	change := DataTankChange[T]{
+		Previous: previous,
+		Current:  d.copyForChange(),
+	}
+
+	return func() {
+		d.subscribers.dispatch(change)
+	}
+}
+
+// Must be called with saveMu held, which is handed over to notifyMu so notify
+// runs in persistence order without blocking the next write.
+func (d *DataTank[T]) dispatchLocked(notify func()) {
+	if notify == nil {
+		d.saveMu.Unlock()
+		return
+	}
+
+	d.notifyMu.Lock()
+	d.saveMu.Unlock()
+	defer d.notifyMu.Unlock()
+
+	notify()
+}
+
+func (s *tankSubscribers[T]) dispatch(change DataTankChange[T]) {
+	s.mu.Lock()
+	callbacks := make([]DataTankSubscribeFn[T], 0, len(s.entries))
+
+	for _, subscriber := range s.entries {
+		if subscriber.channel == nil {
+			callbacks = append(callbacks, subscriber.fn)
+			continue
+		}
+
+		// Sends happen under s.mu, which also guards closing the channel, and
+		// we're the only sender, so there is room after dropping one.
+		queued := change
+		select {
+		case subscriber.channel <- queued:
+		default:
+			select {
+			case dropped := <-subscriber.channel:
+				queued.Dropped += dropped.Dropped + 1
+			default:
+			}
+			subscriber.channel <- queued
+		}
+	}
+	s.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(change)
+	}
+}
@@ -0,0 +1,101 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDataTankSubscribe(t *testing.T) {
+	storage := TankStorageMemory()
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-subscribe")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	changes := make([]DataTankChange[TestData], 0, 4)
+	unsubscribe := tank.Subscribe(func(change DataTankChange[TestData]) {
+		changes = append(changes, change)
+	})
+
+	for i := range 2 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	err = DataTankUpdate(tank, func(data *TestData) error {
+		data.Count = 10
+		return errors.New("rejected")
+	})
+	if err == nil {
+		t.Fatal("Expected update to fail")
+	}
+
+	storage.Write("test-subscribe.tank.json", []byte(`{"Count": 5}`))
+	if err := tank.Reload(); err != nil {
+		t.Fatalf("Failed to reload DataTank: %v", err)
+	}
+
+	expected := [][2]int{{0, 1}, {1, 2}, {2, 5}}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d", len(expected), len(changes))
+	}
+
+	for i, change := range changes {
+		if change.Previous.Count != expected[i][0] || change.Current.Count != expected[i][1] {
+			t.Errorf("Expected change %d to go from %d to %d, got %d to %d", i, expected[i][0], expected[i][1], change.Previous.Count, change.Current.Count)
+		}
+	}
+
+	if changes[0].Current == tank.data {
+		t.Error("Expected Current to be a copy of the tank data")
+	}
+
+	unsubscribe()
+
+	DataTankSet(tank, func(data *TestData) {
+		data.Count = 6
+	})
+
+	if len(changes) != len(expected) {
+		t.Errorf("Expected no changes after unsubscribing, got %d", len(changes))
+	}
+}
+
+func TestDataTankSubscribeChan(t *testing.T) {
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(TankStorageMemory()), "test-subscribe-chan")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	changes, unsubscribe := tank.SubscribeChan(2)
+
+	for i := range 5 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	first := <-changes
+	second := <-changes
+
+	if first.Current.Count != 4 || second.Current.Count != 5 {
+		t.Errorf("Expected the two newest changes to be kept, got %d and %d", first.Current.Count, second.Current.Count)
+	}
+
+	if first.Dropped+second.Dropped != 3 {
+		t.Errorf("Expected 3 dropped changes to be reported, got %d", first.Dropped+second.Dropped)
+	}
+
+	unsubscribe()
+
+	if _, open := <-changes; open {
+		t.Error("Expected channel to be closed after unsubscribing")
+	}
+}
@@ -1,8 +1,12 @@
 package ktnuitygo
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 type TestData struct {
@@ -23,18 +27,18 @@ type TestDataComplex struct {
 
 func TestDataTankSetDir(t *testing.T) {
 	DataTankSetDir("/tmp/test")
-	if tankDir != "/tmp/test" {
-		t.Errorf("Expected tankDir to be '/tmp/test', got '%s'", tankDir)
+	if dir := defaultTankStore.Dir(); dir != "/tmp/test" {
+		t.Errorf("Expected tank dir to be '/tmp/test', got '%s'", dir)
 	}
 
 	DataTankSetDir("/tmp/test/")
-	if tankDir != "/tmp/test" {
-		t.Errorf("Expected tankDir to be '/tmp/test' (trailing slash removed), got '%s'", tankDir)
+	if dir := defaultTankStore.Dir(); dir != "/tmp/test" {
+		t.Errorf("Expected tank dir to be '/tmp/test' (trailing slash removed), got '%s'", dir)
 	}
 
 	DataTankSetDir("")
-	if tankDir != "." {
-		t.Errorf("Expected tankDir to be '.', got '%s'", tankDir)
+	if dir := defaultTankStore.Dir(); dir != "." {
+		t.Errorf("Expected tank dir to be '.', got '%s'", dir)
 	}
 }
 
@@ -259,10 +263,304 @@ func TestTankLoadError(t *testing.T) {
 
 func TestTankPath(t *testing.T) {
 	DataTankSetDir("/test/dir")
-	path := tankPath("mydata")
+	tank := &DataTank[TestData]{
+		name:   "mydata",
+		config: dataTankConfig{codec: TankCodecJSON, storage: defaultTankStore.Storage()},
+	}
+	path := tank.config.storage.(*fileTankStorage).path(tank.fileName())
 	expected := "/test/dir/mydata.tank.json"
 	if path != expected {
 		t.Errorf("Expected path '%s', got '%s'", expected, path)
 	}
 }
 
+
+type TestDataBroken struct {
+	Name     string
+	Callback func()
+}
+
+func TestDataTankSaveAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	tank, err := DataTankNew[TestData]("test-atomic")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	for i := range 3 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read tank directory: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "test-atomic.tank.json" {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only 'test-atomic.tank.json' in tank directory, got %v", names)
+	}
+}
+
+func TestDataTankSaveFailureKeepsFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	original := []byte("{\"Name\": \"Alice\"}\n")
+	path := tmpDir + "/test-broken.tank.json"
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatalf("Failed to create tank file: %v", err)
+	}
+
+	tank, err := DataTankNew[TestDataBroken]("test-broken")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataBroken) {
+		data.Name = "Bob"
+		data.Callback = func() {}
+	})
+	if err == nil {
+		t.Fatal("Expected error when encoding a func field")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read tank file: %v", err)
+	}
+
+	if string(content) != string(original) {
+		t.Errorf("Expected tank file to be untouched, got '%s'", content)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no leftover temp files, got %d entries", len(entries))
+	}
+}
+
+func TestDataTankConcurrentAccess(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	tank, err := DataTankNew[TestData]("test-concurrent")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+			err := DataTankSet(tank, func(data *TestData) {
+				data.Count++
+				data.Meta[fmt.Sprintf("k%d", i)] = i
+			})
+			if err != nil {
+				t.Errorf("Failed to set DataTank data: %v", err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			DataTankGet(tank, func(data *TestData) *int {
+				value := len(data.Meta)
+				return &value
+			})
+		}()
+
+		go func() {
+			defer wg.Done()
+			// Fails until the first save has landed, which is fine here.
+			_ = tank.Reload()
+		}()
+	}
+	wg.Wait()
+
+	if err := tank.Reload(); err != nil {
+		t.Fatalf("Failed to reload DataTank: %v", err)
+	}
+
+	reloaded, err := DataTankNew[TestData]("test-concurrent")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != tank.data.Count {
+		t.Errorf("Expected file and memory to agree, got %d on disk and %d in memory", reloaded.data.Count, tank.data.Count)
+	}
+}
+
+// Runs onRead once, the first time the tank file is read after it was set.
+type hookedTankStorage struct {
+	TankStorage
+	name   string
+	onRead func()
+	once   sync.Once
+}
+
+func (s *hookedTankStorage) Read(name string) ([]byte, error) {
+	content, err := s.TankStorage.Read(name)
+	if name == s.name && s.onRead != nil {
+		s.once.Do(s.onRead)
+	}
+
+	return content, err
+}
+
+func TestDataTankReloadDuringSet(t *testing.T) {
+	storage := &hookedTankStorage{TankStorage: TankStorageMemory(), name: "test-reload-race.tank.json"}
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-reload-race")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if err := tank.Save(); err != nil {
+		t.Fatalf("Failed to save DataTank: %v", err)
+	}
+
+	// A set lands between Reload reading the file and taking over its contents.
+	done := make(chan error, 1)
+	storage.onRead = func() {
+		go func() {
+			done <- DataTankSet(tank, func(data *TestData) {
+				data.Count = 1
+			})
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := tank.Reload(); err != nil {
+		t.Fatalf("Failed to reload DataTank: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if tank.data.Count != 1 {
+		t.Errorf("Expected the set to survive the reload, got Count %d", tank.data.Count)
+	}
+
+	reopened, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-reload-race")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	if reopened.data.Count != 1 {
+		t.Errorf("Expected the set to be saved, got Count %d", reopened.data.Count)
+	}
+}
+
+func TestDataTankUpdate(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	tank, err := DataTankNew[TestData]("test-update")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankUpdate(tank, func(data *TestData) error {
+		data.Count = 5
+		data.Meta["x"] = 1
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update DataTank data: %v", err)
+	}
+
+	errInvalid := errors.New("invalid")
+	err = DataTankUpdate(tank, func(data *TestData) error {
+		data.Count = -1
+		data.Meta["x"] = 2
+		return errInvalid
+	})
+	if !errors.Is(err, errInvalid) {
+		t.Fatalf("Expected callback error to be returned, got %v", err)
+	}
+
+	if tank.data.Count != 5 || tank.data.Meta["x"] != 1 {
+		t.Errorf("Expected tank to be unchanged after failed update, got %+v", *tank.data)
+	}
+
+	reloaded, err := DataTankNew[TestData]("test-update")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 5 {
+		t.Errorf("Expected Count to be 5 on disk, got %d", reloaded.data.Count)
+	}
+}
+
+func TestDataTankUpdateSaveFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	tank, err := DataTankNew[TestDataBroken]("test-update-broken")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankUpdate(tank, func(data *TestDataBroken) error {
+		data.Name = "Bob"
+		data.Callback = func() {}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected error when encoding a func field")
+	}
+
+	if tank.data.Name != "" || tank.data.Callback != nil {
+		t.Errorf("Expected tank to be unchanged after failed save, got Name '%s'", tank.data.Name)
+	}
+}
+
+type TestDataNested struct {
+	Complex TestDataComplex
+	Extra   *TestData
+	List    []TestData
+}
+
+func TestDataTankNestedInit(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	err := os.WriteFile(tmpDir+"/test-nested.tank.json", []byte(`{"List":[{"Name":"a"}]}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write tank file: %v", err)
+	}
+
+	tank, err := DataTankNew[TestDataNested]("test-nested", DataTankWithInitPointers())
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if tank.data.Complex.Data == nil {
+		t.Error("Expected nested map to be initialized")
+	}
+
+	if len(tank.data.List) != 1 || tank.data.List[0].Meta == nil {
+		t.Error("Expected slice element maps to be initialized")
+	}
+
+	if tank.data.Extra == nil || tank.data.Extra.Items == nil {
+		t.Error("Expected Extra to be allocated and initialized")
+	}
+}
@@ -0,0 +1,143 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TankValidator is checked after every load and before every save when
+// implemented by a tank's data type.
+type TankValidator interface {
+	Validate() error
+}
+
+type TankFieldError struct {
+	Field   string
+	Message string
+}
+
+// TankFieldErrors collects failing fields in a validator.
+type TankFieldErrors []TankFieldError
+
+func (errs TankFieldErrors) Add(field string, format string, args ...any) TankFieldErrors {
+	return append(errs, TankFieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Returns nil when no field failed, so validators can end with 'return errs.Err()'.
+func (errs TankFieldErrors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (errs TankFieldErrors) Error() string {
+	parts := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err.Field == "" {
+			parts = append(parts, err.Message)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %s", err.Field, err.Message))
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+var ErrTankInvalid = errors.New("tank data is invalid")
+
+type TankValidationError struct {
+	Tank   string
+	Fields []TankFieldError
+	// What the validator returned.
+	Err error
+}
+
+func (err *TankValidationError) Error() string {
+	return fmt.Sprintf("DataTank '%s' is invalid: %s", err.Tank, TankFieldErrors(err.Fields).Error())
+}
+
+func (err *TankValidationError) Unwrap() error {
+	return err.Err
+}
+
+func (err *TankValidationError) Is(target error) bool {
+	return target == ErrTankInvalid
+}
+
+type tankValidatorFn struct {
+	// The *T the validator was added for.
+	target reflect.Type
+	fn     func(data any) error
+}
+
+// Adds a validator on top of the data type's own TankValidator, if any. Tanks of
+// other types than T skip it, so store options can hold validators for several.
+func DataTankWithValidator[T any](fn func(data *T) error) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.validators = append(config.validators, tankValidatorFn{
+			target: reflect.TypeFor[*T](),
+			fn: func(data any) error {
+				return fn(data.(*T))
+			},
+		})
+	}
+}
+
+func (d *DataTank[T]) validated() bool {
+	for _, validator := range d.config.validators {
+		if validator.target == reflect.TypeFor[*T]() {
+			return true
+		}
+	}
+
+	_, ok := any(new(T)).(TankValidator)
+	return ok
+}
+
+// Returns a *TankValidationError listing the failing fields of every validator.
+func (d *DataTank[T]) validate(data *T) error {
+	var fields TankFieldErrors
+	var errs []error
+
+	check := func(err error) {
+		if err == nil {
+			return
+		}
+
+		errs = append(errs, err)
+
+		var fieldErrs TankFieldErrors
+		if errors.As(err, &fieldErrs) {
+			fields = append(fields, fieldErrs...)
+		} else {
+			fields = append(fields, TankFieldError{Message: err.Error()})
+		}
+	}
+
+	if validator, ok := any(data).(TankValidator); ok {
+		check(validator.Validate())
+	}
+
+	for _, validator := range d.config.validators {
+		if validator.target == reflect.TypeOf(data) {
+			check(validator.fn(data))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &TankValidationError{
+		Tank:   d.name,
+		Fields: fields,
+		Err:    errors.Join(errs...),
+	}
+}
@@ -0,0 +1,131 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"testing"
+)
+
+type TestDataValidated struct {
+	Count int
+	Owner string
+}
+
+func (d *TestDataValidated) Validate() error {
+	var errs TankFieldErrors
+	if d.Count < 0 {
+		errs = errs.Add("Count", "must not be negative, got %d", d.Count)
+	}
+	return errs.Err()
+}
+
+func TestDataTankValidateOnSave(t *testing.T) {
+	storage := TankStorageMemory()
+	ownerRequired := DataTankWithValidator(func(data *TestDataValidated) error {
+		var errs TankFieldErrors
+		if data.Count > 0 && data.Owner == "" {
+			errs = errs.Add("Owner", "is required")
+		}
+		return errs.Err()
+	})
+
+	tank, err := TankStoreOpen[TestDataValidated](TankStoreWithStorage(storage), "test-validate", ownerRequired)
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataValidated) {
+		data.Count = 3
+		data.Owner = "alice"
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataValidated) {
+		data.Count = -1
+		data.Owner = ""
+	})
+	if !errors.Is(err, ErrTankInvalid) {
+		t.Fatalf("Expected ErrTankInvalid, got %v", err)
+	}
+
+	var invalid *TankValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected TankValidationError, got %T", err)
+	}
+
+	if len(invalid.Fields) != 1 || invalid.Fields[0].Field != "Count" {
+		t.Errorf("Expected Count to fail, got %+v", invalid.Fields)
+	}
+
+	err = DataTankSet(tank, func(data *TestDataValidated) {
+		data.Owner = ""
+	})
+	if !errors.As(err, &invalid) || len(invalid.Fields) != 1 || invalid.Fields[0].Field != "Owner" {
+		t.Errorf("Expected Owner to fail, got %v", err)
+	}
+
+	if tank.data.Count != 3 || tank.data.Owner != "alice" {
+		t.Errorf("Expected rejected mutations to leave the tank unchanged, got %+v", *tank.data)
+	}
+}
+
+func TestDataTankValidateOnLoad(t *testing.T) {
+	storage := TankStorageMemory()
+	storage.Write("test-validate.tank.json", []byte(`{"Count": -5}`))
+
+	_, err := TankStoreOpen[TestDataValidated](TankStoreWithStorage(storage), "test-validate")
+
+	var invalid *TankValidationError
+	if !errors.As(err, &invalid) || invalid.Tank != "test-validate" {
+		t.Fatalf("Expected TankValidationError on load, got %v", err)
+	}
+
+	plain := DataTankWithValidator(func(data *TestData) error {
+		return errors.New("always invalid")
+	})
+	storage.Write("test-plain.tank.json", []byte(`{}`))
+
+	_, err = TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-plain", plain)
+	if !errors.As(err, &invalid) || len(invalid.Fields) != 1 || invalid.Fields[0].Message != "always invalid" {
+		t.Errorf("Expected plain validator error to become a field-less entry, got %v", err)
+	}
+}
+
+func TestDataTankValidatorOtherType(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory(), DataTankWithValidator(func(data *TestDataValidated) error {
+		if data.Owner == "" {
+			return errors.New("owner is required")
+		}
+		return nil
+	}))
+
+	// The validator is for another type and must neither panic nor apply.
+	tank, err := TankStoreOpen[TestData](store, "test-other")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if tank.validated() {
+		t.Errorf("Expected no validation for a type without validators")
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 1
+	})
+	if err != nil {
+		t.Errorf("Expected the other type's validator to be skipped, got %v", err)
+	}
+
+	validated, err := TankStoreOpen[TestDataValidated](store, "test-owner")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(validated, func(data *TestDataValidated) {
+		data.Count = 1
+	})
+	if !errors.Is(err, ErrTankInvalid) {
+		t.Errorf("Expected the validator to apply to its own type, got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package ktnuitygo
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type TankWatchOptions struct {
+	// Quiet period after the last change before reloading, defaults to 100ms.
+	Debounce time.Duration
+	// Used when inotify isn't available, defaults to 1s.
+	PollInterval time.Duration
+	// Forces polling even where inotify is available.
+	Poll bool
+	// Receives reload failures, the reload is retried on the next change.
+	OnError ErrorConsumerFn
+}
+
+// previous and current are deep copies, which the callback may keep.
+type DataTankWatchFn[T any] func(previous *T, current *T)
+
+// DataTankWatcher reloads a DataTank whenever its file is changed by someone
+// else. Writes made through the tank itself are recognized and ignored.
+type DataTankWatcher[T any] struct {
+	tank    *DataTank[T]
+	options TankWatchOptions
+	source  tankWatchSource
+	last    [32]byte
+
+	mu        sync.Mutex
+	callbacks []DataTankWatchFn[T]
+
+	done   chan struct{}
+	closed sync.Once
+	wg     sync.WaitGroup
+}
+
+// Signals that one of the watched files may have changed.
+type tankWatchSource interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+var errTankWatchUnsupported = errors.New("native file watching is not supported")
+
+func DataTankWatch[T any](d *DataTank[T], options TankWatchOptions) (*DataTankWatcher[T], error) {
+	if options.Debounce <= 0 {
+		options.Debounce = 100 * time.Millisecond
+	}
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+
+	names := []string{d.fileName()}
+	if d.config.journal != nil {
+		names = append(names, d.journalName())
+	}
+
+	var source tankWatchSource
+	if storage, ok := d.config.storage.(*fileTankStorage); ok && !options.Poll {
+		native, err := newNativeTankWatchSource(storage.dir, names)
+		if err != nil && !errors.Is(err, errTankWatchUnsupported) {
+			return nil, fmt.Errorf("failed to watch DataTank '%s': %w", d.name, err)
+		}
+
+		if err == nil {
+			source = native
+		}
+	}
+
+	if source == nil {
+		source = newPollTankWatchSource(d.config.storage, names, options.PollInterval)
+	}
+
+	d.watched.Store(true)
+	d.saveMu.Lock()
+	d.recordFingerprint()
+	d.saveMu.Unlock()
+
+	watcher := &DataTankWatcher[T]{
+		tank:    d,
+		options: options,
+		source:  source,
+		last:    d.readFingerprint(),
+		done:    make(chan struct{}),
+	}
+
+	watcher.wg.Add(1)
+	go watcher.run()
+
+	return watcher, nil
+}
+
+func (w *DataTankWatcher[T]) OnChange(fn DataTankWatchFn[T]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.callbacks = append(w.callbacks, fn)
+}
+
+func (w *DataTankWatcher[T]) Close() error {
+	var err error
+
+	w.closed.Do(func() {
+		close(w.done)
+		err = w.source.Close()
+		w.wg.Wait()
+	})
+
+	return err
+}
+
+func (w *DataTankWatcher[T]) run() {
+	defer w.wg.Done()
+
+	timer := time.NewTimer(w.options.Debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case _, ok := <-w.source.Events():
+			if !ok {
+				return
+			}
+
+			timer.Reset(w.options.Debounce)
+		case <-timer.C:
+			w.check()
+		}
+	}
+}
+
+func (w *DataTankWatcher[T]) check() {
+	d := w.tank
+
+	current := d.readFingerprint()
+	if current == w.last {
+		return
+	}
+
+	d.saveMu.Lock()
+	own := d.fingerprint
+	d.saveMu.Unlock()
+
+	if current == own {
+		w.last = current
+		return
+	}
+
+	w.mu.Lock()
+	callbacks := append([]DataTankWatchFn[T](nil), w.callbacks...)
+	w.mu.Unlock()
+
+	// Copied under the lock, mutations change the live data in place.
+	var previous *T
+	if len(callbacks) > 0 {
+		d.mu.RLock()
+		previous = deepCopy(d.data)
+		d.mu.RUnlock()
+	}
+
+	if err := d.Reload(); err != nil {
+		if w.options.OnError != nil {
+			w.options.OnError(err)
+		}
+
+		return
+	}
+
+	w.last = current
+
+	if len(callbacks) == 0 {
+		return
+	}
+
+	d.mu.RLock()
+	next := deepCopy(d.data)
+	d.mu.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(previous, next)
+	}
+}
+
+// Hashes everything the tank is loaded from, missing files included.
+func (d *DataTank[T]) readFingerprint() [32]byte {
+	hash := sha256.New()
+
+	names := []string{d.fileName()}
+	if d.config.journal != nil {
+		names = append(names, d.journalName())
+	}
+
+	for _, name := range names {
+		content, err := d.config.storage.Read(name)
+		if err != nil {
+			hash.Write([]byte{0})
+			continue
+		}
+
+		hash.Write([]byte{1})
+		fmt.Fprintf(hash, "%d:", len(content))
+		hash.Write(content)
+	}
+
+	var result [32]byte
+	hash.Sum(result[:0])
+	return result
+}
+
+// Must be called with saveMu held.
+func (d *DataTank[T]) recordFingerprint() {
+	if d.watched.Load() {
+		d.fingerprint = d.readFingerprint()
+	}
+}
+
+type pollTankWatchSource struct {
+	events chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newPollTankWatchSource(storage TankStorage, names []string, interval time.Duration) tankWatchSource {
+	source := &pollTankWatchSource{
+		events: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	go source.run(storage, names, interval)
+	return source
+}
+
+func (s *pollTankWatchSource) run(storage TankStorage, names []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := statTankFiles(storage, names)
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		current := statTankFiles(storage, names)
+		if current == last {
+			continue
+		}
+
+		last = current
+		select {
+		case s.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func statTankFiles(storage TankStorage, names []string) string {
+	result := ""
+	for _, name := range names {
+		info, err := storage.Stat(name)
+		if err != nil {
+			result += "-;"
+			continue
+		}
+
+		result += fmt.Sprintf("%d:%d;", info.Size, info.ModTime.UnixNano())
+	}
+
+	return result
+}
+
+func (s *pollTankWatchSource) Events() <-chan struct{} {
+	return s.events
+}
+
+func (s *pollTankWatchSource) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package ktnuitygo
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"syscall"
+	"unsafe"
+)
+
+type inotifyTankWatchSource struct {
+	file   *os.File
+	events chan struct{}
+}
+
+// Watches the directory rather than the files, atomic writes replace them.
+func newNativeTankWatchSource(dir string, names []string) (tankWatchSource, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init inotify: %w", err)
+	}
+
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE)
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to watch '%s': %w", dir, err)
+	}
+
+	source := &inotifyTankWatchSource{
+		// A non-blocking fd makes reads go through the runtime poller, so Close
+		// interrupts a pending read.
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+	}
+
+	go source.run(names)
+	return source, nil
+}
+
+func (s *inotifyTankWatchSource) run(names []string) {
+	defer close(s.events)
+
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := s.file.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		matched := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			if nameEnd > n {
+				break
+			}
+
+			name := string(buffer[nameStart:nameEnd])
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+
+			matched = matched || slices.Contains(names, name)
+			offset = nameEnd
+		}
+
+		if matched {
+			select {
+			case s.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (s *inotifyTankWatchSource) Events() <-chan struct{} {
+	return s.events
+}
+
+func (s *inotifyTankWatchSource) Close() error {
+	return s.file.Close()
+}
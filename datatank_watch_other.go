@@ -0,0 +1,7 @@
+//go:build !linux
+
+package ktnuitygo
+
+func newNativeTankWatchSource(dir string, names []string) (tankWatchSource, error) {
+	return nil, errTankWatchUnsupported
+}
@@ -0,0 +1,135 @@
+package ktnuitygo
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type testWatchChange struct {
+	previous int
+	current  int
+}
+
+func testWatchTank(t *testing.T, store *TankStore, options TankWatchOptions, write func()) {
+	tank, err := TankStoreOpen[TestData](store, "test-watch")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 1
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	watcher, err := DataTankWatch(tank, options)
+	if err != nil {
+		t.Fatalf("Failed to watch DataTank: %v", err)
+	}
+	defer watcher.Close()
+
+	changes := make(chan testWatchChange, 4)
+	watcher.OnChange(func(previous *TestData, current *TestData) {
+		changes <- testWatchChange{previous.Count, current.Count}
+	})
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 2
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	select {
+	case change := <-changes:
+		t.Fatalf("Expected own write to be ignored, got %+v", change)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	write()
+
+	select {
+	case change := <-changes:
+		if change.previous != 2 || change.current != 7 {
+			t.Errorf("Expected change from 2 to 7, got %+v", change)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected external write to be detected")
+	}
+
+	if tank.data.Count != 7 {
+		t.Errorf("Expected tank to be reloaded with Count 7, got %d", tank.data.Count)
+	}
+}
+
+func TestDataTankWatchPoll(t *testing.T) {
+	storage := TankStorageMemory()
+	options := TankWatchOptions{
+		Debounce:     10 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	testWatchTank(t, TankStoreWithStorage(storage), options, func() {
+		storage.Write("test-watch.tank.json", []byte(`{"Count": 7}`))
+	})
+}
+
+func TestDataTankWatchNative(t *testing.T) {
+	tmpDir := t.TempDir()
+	options := TankWatchOptions{
+		Debounce:     10 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	testWatchTank(t, TankStoreNew(tmpDir), options, func() {
+		os.WriteFile(tmpDir+"/test-watch.tank.json", []byte(`{"Count": 7}`), 0644)
+	})
+}
+
+func TestDataTankWatchCopies(t *testing.T) {
+	storage := TankStorageMemory()
+	options := TankWatchOptions{
+		Debounce:     10 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-watch-copies")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	watcher, err := DataTankWatch(tank, options)
+	if err != nil {
+		t.Fatalf("Failed to watch DataTank: %v", err)
+	}
+	defer watcher.Close()
+
+	changes := make(chan *TestData, 4)
+	watcher.OnChange(func(previous *TestData, current *TestData) {
+		changes <- current
+	})
+
+	// Lets the poller take its first look before the file changes.
+	time.Sleep(50 * time.Millisecond)
+	storage.Write("test-watch-copies.tank.json", []byte(`{"Count": 7}`))
+
+	var current *TestData
+	select {
+	case current = <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected external write to be detected")
+	}
+
+	err = DataTankSet(tank, func(data *TestData) {
+		data.Count = 9
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	if current.Count != 7 {
+		t.Errorf("Expected the callback's data to stay at Count 7, got %d", current.Count)
+	}
+}
@@ -0,0 +1,213 @@
+package ktnuitygo
+
+import (
+	"sync"
+	"time"
+)
+
+type TankWriteBehindPolicy struct {
+	// Saves once no mutation happened for this long.
+	Quiet time.Duration
+	// Saves at the latest this long after the first unsaved mutation, 0 waits
+	// for a quiet period no matter how long it takes.
+	MaxDelay time.Duration
+	// Receives errors from background saves, they are also returned by Flush.
+	OnError ErrorConsumerFn
+}
+
+type tankWriteBehind struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending bool
+	since   time.Time
+	err     error
+	closed  bool
+	// Background saves in progress, idle is closed once the last one is done.
+	running int
+	idle    chan struct{}
+}
+
+// Applies DataTankSet and DataTankUpdate in memory right away and saves in the
+// background according to policy. Call Flush or Close before exiting so no
+// mutation is lost. Ignored together with DataTankWithFileLock, which needs
+// every mutation to hit the file.
+func DataTankWithWriteBehind(policy TankWriteBehindPolicy) DataTankOption {
+	return func(config *dataTankConfig) {
+		config.behind = &policy
+	}
+}
+
+func (d *DataTank[T]) deferred() bool {
+	if d.config.behind == nil || d.config.fileLock {
+		return false
+	}
+
+	d.behind.mu.Lock()
+	defer d.behind.mu.Unlock()
+
+	return !d.behind.closed
+}
+
+// Must be called with mu held, unlock releases it. Schedules a save instead of
+// saving, notify is dispatched right away.
+func (d *DataTank[T]) deferSaveLocked(unlock func(), notify func()) error {
+	d.behind.schedule(d.config.behind, func() {
+		if !d.behind.begin() {
+			return
+		}
+		defer d.behind.end()
+
+		if err := d.Save(); err != nil {
+			d.behind.fail(err)
+			if d.config.behind.OnError != nil {
+				d.config.behind.OnError(err)
+			}
+		}
+	})
+
+	d.saveMu.Lock()
+	unlock()
+	d.dispatchLocked(notify)
+	return nil
+}
+
+// Saves pending mutations right away, waits for background saves already under
+// way and returns the error of a failed one, if there was one since the last
+// Flush.
+func (d *DataTank[T]) Flush() error {
+	if d.behind.isPending() {
+		if err := d.Save(); err != nil {
+			return err
+		}
+	}
+
+	d.behind.wait()
+	return d.behind.takeErr()
+}
+
+// Flushes pending mutations and turns off write-behind, later mutations are
+// saved right away.
+func (d *DataTank[T]) Close() error {
+	d.behind.mu.Lock()
+	d.behind.closed = true
+	d.behind.mu.Unlock()
+
+	return d.Flush()
+}
+
+func (b *tankWriteBehind) schedule(policy *TankWriteBehindPolicy, save func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if !b.pending {
+		b.pending = true
+		b.since = now
+	}
+
+	delay := policy.Quiet
+	if policy.MaxDelay > 0 {
+		delay = min(delay, b.since.Add(policy.MaxDelay).Sub(now))
+	}
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+
+	b.timer = time.AfterFunc(max(delay, 0), save)
+}
+
+// Clears the pending flag ahead of a save, returns whether it was set.
+func (b *tankWriteBehind) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending := b.pending
+	b.pending = false
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	return pending
+}
+
+// Puts the pending flag back after a failed save, the next mutation or Flush
+// retries it.
+func (b *tankWriteBehind) restore(pending bool) {
+	if !pending {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.pending {
+		b.pending = true
+		b.since = time.Now()
+	}
+}
+
+// Registers a background save, unless its mutations were already taken by
+// another save.
+func (b *tankWriteBehind) begin() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.pending {
+		return false
+	}
+
+	if b.running == 0 {
+		b.idle = make(chan struct{})
+	}
+
+	b.running++
+	return true
+}
+
+func (b *tankWriteBehind) end() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.running--
+	if b.running == 0 {
+		close(b.idle)
+	}
+}
+
+// Waits for the background saves registered so far.
+func (b *tankWriteBehind) wait() {
+	b.mu.Lock()
+	idle := b.idle
+	running := b.running
+	b.mu.Unlock()
+
+	if running > 0 {
+		<-idle
+	}
+}
+
+func (b *tankWriteBehind) isPending() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pending
+}
+
+func (b *tankWriteBehind) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+}
+
+func (b *tankWriteBehind) takeErr() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.err
+	b.err = nil
+	return err
+}
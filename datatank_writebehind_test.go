@@ -0,0 +1,173 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestDataTankWriteBehindQuiet(t *testing.T) {
+	storage := TankStorageMemory()
+	store := TankStoreWithStorage(storage, DataTankWithWriteBehind(TankWriteBehindPolicy{Quiet: 30 * time.Millisecond}))
+
+	tank, err := TankStoreOpen[TestData](store, "test-behind")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	for i := range 10 {
+		err = DataTankSet(tank, func(data *TestData) {
+			data.Count = i + 1
+		})
+		if err != nil {
+			t.Fatalf("Failed to set DataTank data: %v", err)
+		}
+	}
+
+	if tank.data.Count != 10 {
+		t.Errorf("Expected mutations to apply in memory right away, got Count %d", tank.data.Count)
+	}
+
+	if _, err := storage.Stat("test-behind.tank.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected no save before the quiet period, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	reloaded, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-behind")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 10 {
+		t.Errorf("Expected background save with Count 10, got %d", reloaded.data.Count)
+	}
+}
+
+func TestDataTankWriteBehindMaxDelay(t *testing.T) {
+	storage := TankStorageMemory()
+	policy := TankWriteBehindPolicy{Quiet: time.Hour, MaxDelay: 20 * time.Millisecond}
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-max-delay", DataTankWithWriteBehind(policy))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	DataTankSet(tank, func(data *TestData) {
+		data.Count = 1
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := storage.Stat("test-max-delay.tank.json"); err != nil {
+		t.Errorf("Expected save after the max delay, got %v", err)
+	}
+}
+
+func TestDataTankWriteBehindFlushAndClose(t *testing.T) {
+	storage := TankStorageMemory()
+	policy := TankWriteBehindPolicy{Quiet: time.Hour}
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-flush", DataTankWithWriteBehind(policy))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	DataTankSet(tank, func(data *TestData) {
+		data.Count = 1
+	})
+
+	if err := tank.Flush(); err != nil {
+		t.Fatalf("Failed to flush DataTank: %v", err)
+	}
+
+	content, err := storage.Read("test-flush.tank.json")
+	if err != nil {
+		t.Fatalf("Expected tank file after flush: %v", err)
+	}
+
+	DataTankSet(tank, func(data *TestData) {
+		data.Count = 2
+	})
+
+	if err := tank.Close(); err != nil {
+		t.Fatalf("Failed to close DataTank: %v", err)
+	}
+
+	after, _ := storage.Read("test-flush.tank.json")
+	if string(after) == string(content) {
+		t.Error("Expected Close to flush the pending mutation")
+	}
+
+	DataTankSet(tank, func(data *TestData) {
+		data.Count = 3
+	})
+
+	reloaded, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-flush")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	if reloaded.data.Count != 3 {
+		t.Errorf("Expected mutations after Close to be saved right away, got Count %d", reloaded.data.Count)
+	}
+}
+
+type slowTankStorage struct {
+	TankStorage
+	delay time.Duration
+	err   error
+}
+
+func (s *slowTankStorage) Write(name string, data []byte) error {
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return s.err
+	}
+
+	return s.TankStorage.Write(name, data)
+}
+
+func TestDataTankWriteBehindCloseWaits(t *testing.T) {
+	storage := &slowTankStorage{TankStorage: TankStorageMemory(), delay: 100 * time.Millisecond}
+	policy := TankWriteBehindPolicy{Quiet: time.Millisecond}
+
+	tank, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-slow", DataTankWithWriteBehind(policy))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	DataTankSet(tank, func(data *TestData) {
+		data.Count = 1
+	})
+
+	// The background save is under way, nothing is pending anymore.
+	time.Sleep(20 * time.Millisecond)
+
+	if err := tank.Close(); err != nil {
+		t.Fatalf("Failed to close DataTank: %v", err)
+	}
+
+	if _, err := storage.Stat("test-slow.tank.json"); err != nil {
+		t.Errorf("Expected Close to wait for the background save, got %v", err)
+	}
+
+	errFailed := errors.New("disk full")
+	storage.err = errFailed
+
+	failing, err := TankStoreOpen[TestData](TankStoreWithStorage(storage), "test-slow-fail", DataTankWithWriteBehind(policy))
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	DataTankSet(failing, func(data *TestData) {
+		data.Count = 1
+	})
+
+	time.Sleep(20 * time.Millisecond)
+
+	if err := failing.Close(); !errors.Is(err, errFailed) {
+		t.Errorf("Expected Close to return the failed background save, got %v", err)
+	}
+}
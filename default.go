@@ -0,0 +1,174 @@
+package ktnuitygo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Reported when a `default:"..."` struct tag can't be parsed into its field.
+type DefaultTagError struct {
+	Type  reflect.Type
+	Field string
+	Tag   string
+	Err   error
+}
+
+func (err *DefaultTagError) Error() string {
+	return fmt.Sprintf("invalid default tag on '%s.%s' (%q): %v", err.Type.Name(), err.Field, err.Tag, err.Err)
+}
+
+func (err *DefaultTagError) Unwrap() error {
+	return err.Err
+}
+
+type defaultKey struct {
+	owner reflect.Type
+	index int
+}
+
+type defaultValue struct {
+	value reflect.Value
+	err   error
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// Parses a default tag into a value of type t. Scalars are written as is,
+// durations in time.ParseDuration format and anything else as JSON.
+func parseDefault(t reflect.Type, tag string) (reflect.Value, error) {
+	value := reflect.New(t).Elem()
+
+	if t == durationType {
+		duration, err := time.ParseDuration(tag)
+		if err != nil {
+			return value, err
+		}
+
+		value.SetInt(int64(duration))
+		return value, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		value.SetString(tag)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(tag)
+		if err != nil {
+			return value, err
+		}
+
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(tag, 10, t.Bits())
+		if err != nil {
+			return value, err
+		}
+
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(tag, 10, t.Bits())
+		if err != nil {
+			return value, err
+		}
+
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(tag, t.Bits())
+		if err != nil {
+			return value, err
+		}
+
+		value.SetFloat(parsed)
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.Struct, reflect.Pointer:
+		if err := json.Unmarshal([]byte(tag), value.Addr().Interface()); err != nil {
+			return value, err
+		}
+	default:
+		return value, fmt.Errorf("unsupported field type %s", t)
+	}
+
+	return value, nil
+}
+
+// Tanks split initialization around decoding, so that zero values stored in a
+// file aren't replaced by defaults. Only fields missing from the file keep them,
+// which includes zero values left out by the codec, as gob and omitempty do.
+type initPhase int
+
+const (
+	// Defaults and empty collections at once, for values that weren't decoded.
+	initAll initPhase = iota
+	// Defaults on a fresh value, before decoding into it. Fields decoding would
+	// merge with the file's are left out, see defaultAfterDecode.
+	initBeforeDecode
+	// Empty collections and the defaults of fields left out before decoding, if
+	// still zero. Other fields are kept as decoded, including those of elements.
+	initAfterDecode
+)
+
+// Reports whether a default of type t has to wait until after decoding.
+// encoding/json decodes into existing map entries and slice and array elements,
+// so a default's elements would leak into the file's.
+func defaultAfterDecode(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice:
+		return true
+	case reflect.Array:
+		switch t.Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+			return true
+		}
+	}
+
+	return false
+}
+
+func initWithPhase(phase initPhase) InitOption {
+	return func(config *initConfig) {
+		config.phase = phase
+	}
+}
+
+// Sets field, the index-th field of owner, to its default if it has one and is
+// still zero. Parsed defaults are cached per walk, so each bad tag reports once.
+func (w *initWalker) applyDefault(owner reflect.Type, index int, field reflect.Value) {
+	info := owner.Field(index)
+	tag, ok := info.Tag.Lookup("default")
+	if !ok || !field.IsZero() {
+		return
+	}
+
+	switch w.config.phase {
+	case initBeforeDecode:
+		if defaultAfterDecode(field.Type()) {
+			return
+		}
+	case initAfterDecode:
+		if !defaultAfterDecode(field.Type()) {
+			return
+		}
+	}
+
+	key := defaultKey{owner: owner, index: index}
+	parsed, cached := w.defaults[key]
+	if !cached {
+		value, err := parseDefault(info.Type, tag)
+		if err != nil {
+			err = &DefaultTagError{Type: owner, Field: info.Name, Tag: tag, Err: err}
+			w.errs = append(w.errs, err)
+		}
+
+		parsed = defaultValue{value: value, err: err}
+		w.defaults[key] = parsed
+	}
+
+	if parsed.err != nil {
+		return
+	}
+
+	// Copied so that slices and maps aren't shared between fields.
+	copyValue(field, parsed.value, make(map[uintptr]reflect.Value))
+}
@@ -0,0 +1,259 @@
+package ktnuitygo
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type defaultInner struct {
+	Retries int    `default:"3"`
+	Mode    string `default:"fast"`
+}
+
+type defaultConfig struct {
+	Name     string         `default:"tank"`
+	Enabled  bool           `default:"true"`
+	Limit    uint16         `default:"512"`
+	Ratio    float64        `default:"0.5"`
+	Timeout  time.Duration  `default:"30s"`
+	Tags     []string       `default:"[\"a\",\"b\"]"`
+	Weights  map[string]int `default:"{\"x\":1}"`
+	Inner    defaultInner
+	Fallback defaultInner `default:"{\"Retries\":7}"`
+	Items    []defaultInner
+	ByName   map[string]defaultInner
+	Plain    int
+}
+
+func TestInitDefaultTags(t *testing.T) {
+	result := InitDefault[defaultConfig]()
+
+	if result.Name != "tank" || !result.Enabled || result.Limit != 512 || result.Ratio != 0.5 {
+		t.Errorf("Expected scalar defaults to be set, got %+v", result)
+	}
+
+	if result.Timeout != 30*time.Second {
+		t.Errorf("Expected Timeout to be 30s, got %v", result.Timeout)
+	}
+
+	if len(result.Tags) != 2 || result.Tags[0] != "a" || result.Tags[1] != "b" {
+		t.Errorf("Expected Tags to be [a b], got %v", result.Tags)
+	}
+
+	if result.Weights["x"] != 1 {
+		t.Errorf("Expected Weights[x] to be 1, got %v", result.Weights)
+	}
+
+	if result.Inner.Retries != 3 || result.Inner.Mode != "fast" {
+		t.Errorf("Expected nested defaults to be set, got %+v", result.Inner)
+	}
+
+	if result.Fallback.Retries != 7 || result.Fallback.Mode != "fast" {
+		t.Errorf("Expected Fallback to be {7 fast}, got %+v", result.Fallback)
+	}
+
+	if result.Items == nil || result.ByName == nil || result.Plain != 0 {
+		t.Errorf("Expected untagged fields to be initialized only, got %+v", result)
+	}
+}
+
+func TestDefaultTagsFillZeroOnly(t *testing.T) {
+	value := defaultConfig{
+		Name:    "custom",
+		Timeout: time.Minute,
+		Tags:    []string{},
+		Items:   []defaultInner{{Retries: 1}},
+		ByName:  map[string]defaultInner{"a": {Mode: "slow"}},
+	}
+
+	if err := verify(&value, false); err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+
+	if value.Name != "custom" || value.Timeout != time.Minute {
+		t.Errorf("Expected set fields to be kept, got %+v", value)
+	}
+
+	if value.Tags == nil || len(value.Tags) != 0 {
+		t.Errorf("Expected empty Tags to be kept, got %v", value.Tags)
+	}
+
+	if value.Items[0].Retries != 1 || value.Items[0].Mode != "fast" {
+		t.Errorf("Expected slice element to be {1 fast}, got %+v", value.Items[0])
+	}
+
+	if value.ByName["a"].Retries != 3 || value.ByName["a"].Mode != "slow" {
+		t.Errorf("Expected map value to be {3 slow}, got %+v", value.ByName["a"])
+	}
+}
+
+func TestDefaultTagsNotShared(t *testing.T) {
+	first := InitDefault[defaultConfig]()
+	second := InitDefault[defaultConfig]()
+
+	first.Tags[0] = "changed"
+	if second.Tags[0] != "a" {
+		t.Errorf("Expected defaults not to be shared, got %v", second.Tags)
+	}
+}
+
+type defaultBroken struct {
+	Count int `default:"many"`
+	Items []defaultBrokenItem
+}
+
+type defaultBrokenItem struct {
+	Delay time.Duration `default:"soon"`
+}
+
+func TestDefaultTagErrors(t *testing.T) {
+	var reported []error
+	result := InitDefault[defaultBroken](InitWithErrorConsumer(func(err error) {
+		reported = append(reported, err)
+	}))
+
+	if len(reported) != 1 {
+		t.Fatalf("Expected 1 error, got %d: %v", len(reported), reported)
+	}
+
+	var tagErr *DefaultTagError
+	if !errors.As(reported[0], &tagErr) {
+		t.Fatalf("Expected DefaultTagError, got %T", reported[0])
+	}
+
+	if tagErr.Field != "Count" || tagErr.Tag != "many" {
+		t.Errorf("Expected error for Count with tag 'many', got %v", tagErr)
+	}
+
+	if result.Count != 0 || result.Items == nil {
+		t.Errorf("Expected broken field to stay zero, got %+v", result)
+	}
+
+	value := defaultBroken{Items: []defaultBrokenItem{{}, {}}}
+	err := verify(&value, false)
+	if !errors.As(err, &tagErr) {
+		t.Fatalf("Expected DefaultTagError, got %v", err)
+	}
+
+	if count := len(err.(interface{ Unwrap() []error }).Unwrap()); count != 2 {
+		t.Errorf("Expected each bad tag to be reported once, got %d errors: %v", count, err)
+	}
+}
+
+func TestDataTankDefaultTags(t *testing.T) {
+	tmpDir := t.TempDir()
+	DataTankSetDir(tmpDir)
+
+	tank, err := DataTankNew[defaultConfig]("test-default")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if tank.data.Timeout != 30*time.Second || tank.data.Inner.Retries != 3 {
+		t.Errorf("Expected defaults on a new tank, got %+v", *tank.data)
+	}
+
+	err = os.WriteFile(tmpDir+"/test-default-old.tank.json", []byte(`{"Name":"old","Items":[{"Mode":"slow"}]}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write tank file: %v", err)
+	}
+
+	old, err := DataTankNew[defaultConfig]("test-default-old")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	if old.data.Name != "old" || old.data.Limit != 512 {
+		t.Errorf("Expected missing fields to be defaulted, got %+v", *old.data)
+	}
+
+	// Elements are created by decoding, so there is nothing to tell missing fields apart.
+	if old.data.Items[0].Retries != 0 || old.data.Items[0].Mode != "slow" {
+		t.Errorf("Expected decoded slice element to be kept as {0 slow}, got %+v", old.data.Items[0])
+	}
+
+	if old.data.Weights["x"] != 1 || old.data.Inner.Retries != 3 {
+		t.Errorf("Expected missing map and nested fields to be defaulted, got %+v", *old.data)
+	}
+
+	_, err = DataTankNew[defaultBroken]("test-default-broken")
+	var tagErr *DefaultTagError
+	if !errors.As(err, &tagErr) {
+		t.Errorf("Expected DefaultTagError from DataTankNew, got %v", err)
+	}
+}
+
+func TestDataTankDefaultTagsKeepZero(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+
+	tank, err := TankStoreOpen[defaultConfig](store, "test-default-zero")
+	if err != nil {
+		t.Fatalf("Failed to create DataTank: %v", err)
+	}
+
+	err = DataTankSet(tank, func(data *defaultConfig) {
+		data.Enabled = false
+		data.Limit = 0
+		data.Tags = []string{}
+		data.Weights = map[string]int{}
+		data.Inner.Retries = 0
+	})
+	if err != nil {
+		t.Fatalf("Failed to set DataTank data: %v", err)
+	}
+
+	reopened, err := TankStoreOpen[defaultConfig](store, "test-default-zero")
+	if err != nil {
+		t.Fatalf("Failed to reopen DataTank: %v", err)
+	}
+
+	data := reopened.data
+	if data.Enabled || data.Limit != 0 || data.Inner.Retries != 0 {
+		t.Errorf("Expected saved zero values to be kept, got %+v", *data)
+	}
+
+	if len(data.Tags) != 0 || len(data.Weights) != 0 {
+		t.Errorf("Expected saved empty collections to be kept, got %v, %v", data.Tags, data.Weights)
+	}
+
+	if data.Name != "tank" || data.Timeout != 30*time.Second {
+		t.Errorf("Expected untouched defaults to be kept, got %+v", *data)
+	}
+}
+
+type defaultPair struct {
+	A int
+	B int
+}
+
+type defaultLists struct {
+	List  []defaultPair  `default:"[{\"A\":1,\"B\":2}]"`
+	Fixed [1]defaultPair `default:"[{\"A\":1,\"B\":2}]"`
+}
+
+func TestDataTankDefaultTagsSliceElements(t *testing.T) {
+	store := TankStoreWithStorage(TankStorageMemory())
+	store.Storage().Write("test-default-list.tank.json", []byte(`{"List":[{"A":5}],"Fixed":[{"A":5}]}`))
+
+	tank, err := TankStoreOpen[defaultLists](store, "test-default-list")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	expected := defaultPair{A: 5}
+	if len(tank.data.List) != 1 || tank.data.List[0] != expected || tank.data.Fixed[0] != expected {
+		t.Errorf("Expected the file's elements without the default's fields, got %+v", *tank.data)
+	}
+
+	missing, err := TankStoreOpen[defaultLists](store, "test-default-list-missing")
+	if err != nil {
+		t.Fatalf("Failed to open DataTank: %v", err)
+	}
+
+	defaulted := defaultPair{A: 1, B: 2}
+	if len(missing.data.List) != 1 || missing.data.List[0] != defaulted || missing.data.Fixed[0] != defaulted {
+		t.Errorf("Expected defaults without a file, got %+v", *missing.data)
+	}
+}
@@ -2,4 +2,8 @@ module github.com/ktnuity/ktnuitygo
 
 go 1.25.1
 
-require github.com/emirpasic/gods v1.18.1
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/emirpasic/gods v1.18.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,553 @@
+package ktnuitygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Round trips value through encoding/json into its generic form of maps,
+// slices and scalars, as used by the patch functions.
+func toJsonDoc(value any) (any, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJsonDoc(content)
+}
+
+// Decodes content into its generic form, keeping numbers as json.Number so
+// integers above 2^53 survive the round trip.
+func decodeJsonDoc(content []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+
+	return doc, nil
+}
+
+// Builds an RFC 7396 merge patch turning from into to. Null values can't be
+// expressed by a merge patch, so they become removals.
+func mergePatchDiff(from, to any) any {
+	fromMap, fromIsMap := from.(map[string]any)
+	toMap, toIsMap := to.(map[string]any)
+
+	if !fromIsMap || !toIsMap {
+		return to
+	}
+
+	patch := make(map[string]any)
+
+	for key := range fromMap {
+		if _, exists := toMap[key]; !exists {
+			patch[key] = nil
+		}
+	}
+
+	for key, value := range toMap {
+		previous, exists := fromMap[key]
+		if !exists {
+			patch[key] = value
+			continue
+		}
+
+		if jsonEqual(previous, value) {
+			continue
+		}
+
+		_, previousIsMap := previous.(map[string]any)
+		_, valueIsMap := value.(map[string]any)
+		if previousIsMap && valueIsMap {
+			patch[key] = mergePatchDiff(previous, value)
+		} else {
+			patch[key] = value
+		}
+	}
+
+	return patch
+}
+
+// Applies an RFC 7396 merge patch to target, which may be modified in place.
+func mergePatchApply(target, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]any)
+	if !ok {
+		targetMap = make(map[string]any, len(patchMap))
+	}
+
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+			continue
+		}
+
+		targetMap[key] = mergePatchApply(targetMap[key], value)
+	}
+
+	return targetMap
+}
+
+var ErrJsonPatchTestFailed = errors.New("test operation failed")
+
+// A single RFC 6902 operation. From is used by move and copy, Value by add,
+// replace and test.
+type JsonPatchOp struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+type JsonPatch []JsonPatchOp
+
+func (op JsonPatchOp) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{"op": op.Op, "path": op.Path}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		fields["value"] = op.Value
+	case "move", "copy":
+		fields["from"] = op.From
+	}
+
+	return json.Marshal(fields)
+}
+
+func (op *JsonPatchOp) UnmarshalJSON(content []byte) error {
+	var fields struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return err
+	}
+
+	if fields.Path == nil {
+		return fmt.Errorf("'%s' operation is missing 'path'", fields.Op)
+	}
+
+	*op = JsonPatchOp{Op: fields.Op, Path: *fields.Path}
+
+	switch fields.Op {
+	case "add", "replace", "test":
+		if fields.Value == nil {
+			return fmt.Errorf("'%s' operation is missing 'value'", fields.Op)
+		}
+
+		value, err := decodeJsonDoc(fields.Value)
+		if err != nil {
+			return err
+		}
+
+		op.Value = value
+	case "move", "copy":
+		if fields.From == nil {
+			return fmt.Errorf("'%s' operation is missing 'from'", fields.Op)
+		}
+
+		op.From = *fields.From
+	case "remove":
+	default:
+		return fmt.Errorf("unknown operation '%s'", fields.Op)
+	}
+
+	return nil
+}
+
+// Splits an RFC 6901 JSON pointer into its unescaped reference tokens.
+func parseJsonPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func formatJsonPointer(tokens []string) string {
+	var builder strings.Builder
+	for _, token := range tokens {
+		builder.WriteByte('/')
+		builder.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+
+	return builder.String()
+}
+
+// Parses token as an index into array. With end, '-' and len(array) are allowed
+// too, referring to the position after the last element.
+func jsonPointerIndex(array []any, token string, end bool) (int, error) {
+	limit := len(array)
+	if end {
+		if token == "-" {
+			return limit, nil
+		}
+
+		limit++
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= limit || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+
+	return index, nil
+}
+
+func jsonPointerGet(doc any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("member '%s' does not exist", token)
+			}
+
+			doc = value
+		case []any:
+			index, err := jsonPointerIndex(node, token, false)
+			if err != nil {
+				return nil, err
+			}
+
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("cannot reference '%s' in a scalar", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// Calls fn with the container of the last token and returns the document with
+// whatever fn returns in place of that container.
+func jsonPointerUpdate(doc any, tokens []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, exists := node[tokens[0]]
+		if !exists {
+			return nil, fmt.Errorf("member '%s' does not exist", tokens[0])
+		}
+
+		updated, err := jsonPointerUpdate(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+
+		node[tokens[0]] = updated
+		return node, nil
+	case []any:
+		index, err := jsonPointerIndex(node, tokens[0], false)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := jsonPointerUpdate(node[index], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+
+		node[index] = updated
+		return node, nil
+	default:
+		return nil, fmt.Errorf("cannot reference '%s' in a scalar", tokens[0])
+	}
+}
+
+func jsonPatchAdd(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return jsonPointerUpdate(doc, tokens, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			index, err := jsonPointerIndex(node, token, true)
+			if err != nil {
+				return nil, err
+			}
+
+			return slices.Insert(node, index, value), nil
+		default:
+			return nil, fmt.Errorf("cannot add '%s' to a scalar", token)
+		}
+	})
+}
+
+// Returns the document without the value at tokens, along with that value.
+func jsonPatchRemove(doc any, tokens []string) (any, any, error) {
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed any
+	doc, err := jsonPointerUpdate(doc, tokens, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("member '%s' does not exist", token)
+			}
+
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []any:
+			index, err := jsonPointerIndex(node, token, false)
+			if err != nil {
+				return nil, err
+			}
+
+			removed = node[index]
+			return slices.Delete(node, index, index+1), nil
+		default:
+			return nil, fmt.Errorf("cannot remove '%s' from a scalar", token)
+		}
+	})
+
+	return doc, removed, err
+}
+
+// Applies an RFC 6902 JSON patch to doc, which may be modified in place even if
+// an operation fails. Operations see the result of the ones before them.
+func jsonPatchApply(doc any, patch JsonPatch) (any, error) {
+	for i, op := range patch {
+		var err error
+		doc, err = jsonPatchApplyOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s '%s'): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func jsonPatchApplyOp(doc any, op JsonPatchOp) (any, error) {
+	path, err := parseJsonPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round tripped so the document never shares the patch's values and values
+	// built in Go compare to decoded ones, numbers being json.Number in both.
+	value, err := toJsonDoc(op.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return jsonPatchAdd(doc, path, value)
+	case "remove":
+		doc, _, err = jsonPatchRemove(doc, path)
+		return doc, err
+	case "replace":
+		if _, err := jsonPointerGet(doc, path); err != nil {
+			return nil, err
+		}
+
+		if len(path) == 0 {
+			return value, nil
+		}
+
+		doc, _, err = jsonPatchRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonPatchAdd(doc, path, value)
+	case "move", "copy":
+		from, err := parseJsonPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+				return nil, fmt.Errorf("cannot move '%s' into itself", op.From)
+			}
+
+			doc, value, err = jsonPatchRemove(doc, from)
+		} else {
+			value, err = jsonPointerGet(doc, from)
+			value = deepCopyJsonDoc(value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonPatchAdd(doc, path, value)
+	case "test":
+		current, err := jsonPointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !jsonEqual(current, value) {
+			return nil, ErrJsonPatchTestFailed
+		}
+
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation '%s'", op.Op)
+	}
+}
+
+// Builds an RFC 6902 JSON patch turning from into to. Arrays of different
+// lengths are replaced as a whole.
+func jsonPatchDiff(from, to any) JsonPatch {
+	return jsonPatchDiffAt(nil, from, to, JsonPatch{})
+}
+
+func jsonPatchDiffAt(path []string, from, to any, patch JsonPatch) JsonPatch {
+	if jsonEqual(from, to) {
+		return patch
+	}
+
+	switch fromNode := from.(type) {
+	case map[string]any:
+		toNode, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(fromNode)) {
+			if _, exists := toNode[key]; !exists {
+				patch = append(patch, JsonPatchOp{Op: "remove", Path: formatJsonPointer(append(slices.Clone(path), key))})
+			}
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(toNode)) {
+			child := append(slices.Clone(path), key)
+			if previous, exists := fromNode[key]; exists {
+				patch = jsonPatchDiffAt(child, previous, toNode[key], patch)
+			} else {
+				patch = append(patch, JsonPatchOp{Op: "add", Path: formatJsonPointer(child), Value: toNode[key]})
+			}
+		}
+
+		return patch
+	case []any:
+		toNode, ok := to.([]any)
+		if !ok || len(fromNode) != len(toNode) {
+			break
+		}
+
+		for i := range fromNode {
+			patch = jsonPatchDiffAt(append(slices.Clone(path), strconv.Itoa(i)), fromNode[i], toNode[i], patch)
+		}
+
+		return patch
+	}
+
+	return append(patch, JsonPatchOp{Op: "replace", Path: formatJsonPointer(path), Value: to})
+}
+
+// Compares generic JSON documents, with numbers compared by value so that 1 and
+// 1.0 are equal while integers beyond float64 precision are still told apart.
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for key, value := range a {
+			other, exists := b[key]
+			if !exists || !jsonEqual(value, other) {
+				return false
+			}
+		}
+
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+
+		if a == b {
+			return true
+		}
+
+		x, okA := new(big.Rat).SetString(string(a))
+		y, okB := new(big.Rat).SetString(string(b))
+		return okA && okB && x.Cmp(y) == 0
+	default:
+		return a == b
+	}
+}
+
+func deepCopyJsonDoc(doc any) any {
+	switch node := doc.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(node))
+		for key, value := range node {
+			result[key] = deepCopyJsonDoc(value)
+		}
+
+		return result
+	case []any:
+		result := make([]any, len(node))
+		for i, value := range node {
+			result[i] = deepCopyJsonDoc(value)
+		}
+
+		return result
+	default:
+		return doc
+	}
+}
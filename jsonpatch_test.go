@@ -0,0 +1,146 @@
+package ktnuitygo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	from := map[string]any{"a": 1.0, "b": map[string]any{"c": "x", "d": "y"}, "e": []any{1.0}}
+	to := map[string]any{"a": 1.0, "b": map[string]any{"c": "z"}, "f": true}
+
+	patch := mergePatchDiff(from, to)
+	expected := map[string]any{"b": map[string]any{"c": "z", "d": nil}, "e": nil, "f": true}
+
+	if !reflect.DeepEqual(patch, expected) {
+		t.Errorf("Expected patch %v, got %v", expected, patch)
+	}
+
+	result := mergePatchApply(from, patch)
+	if !reflect.DeepEqual(result, to) {
+		t.Errorf("Expected patched document %v, got %v", to, result)
+	}
+}
+
+func TestJsonPatchApply(t *testing.T) {
+	doc, _ := decodeJsonDoc([]byte(`{"a":{"b":[1,2]},"c~d":"x","e/f":true}`))
+
+	var patch JsonPatch
+	err := json.Unmarshal([]byte(`[
+		{"op":"test","path":"/c~0d","value":"x"},
+		{"op":"add","path":"/a/b/-","value":3},
+		{"op":"add","path":"/a/b/0","value":0},
+		{"op":"remove","path":"/e~1f"},
+		{"op":"replace","path":"/c~0d","value":null},
+		{"op":"copy","from":"/a/b","path":"/copied"},
+		{"op":"move","from":"/a/b/3","path":"/moved"}
+	]`), &patch)
+	if err != nil {
+		t.Fatalf("Failed to decode patch: %v", err)
+	}
+
+	result, err := jsonPatchApply(doc, patch)
+	if err != nil {
+		t.Fatalf("Failed to apply patch: %v", err)
+	}
+
+	expected, _ := decodeJsonDoc([]byte(`{"a":{"b":[0,1,2]},"c~d":null,"copied":[0,1,2,3],"moved":3}`))
+
+	if !jsonEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestJsonPatchApplyErrors(t *testing.T) {
+	cases := []string{
+		`[{"op":"test","path":"/a","value":2}]`,
+		`[{"op":"remove","path":"/missing"}]`,
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"add","path":"/b/5","value":1}]`,
+		`[{"op":"add","path":"/b/01","value":1}]`,
+		`[{"op":"move","from":"/b","path":"/b/0"}]`,
+		`[{"op":"add","path":"a","value":1}]`,
+	}
+
+	for _, content := range cases {
+		doc, _ := decodeJsonDoc([]byte(`{"a":1,"b":[1]}`))
+
+		var patch JsonPatch
+		if err := json.Unmarshal([]byte(content), &patch); err != nil {
+			t.Fatalf("Failed to decode patch %s: %v", content, err)
+		}
+
+		if _, err := jsonPatchApply(doc, patch); err == nil {
+			t.Errorf("Expected patch %s to fail", content)
+		}
+	}
+
+	for _, content := range []string{`[{"op":"add","path":"/a"}]`, `[{"op":"jump","path":"/a"}]`, `[{"op":"move","path":"/a"}]`} {
+		var patch JsonPatch
+		if err := json.Unmarshal([]byte(content), &patch); err == nil {
+			t.Errorf("Expected patch %s not to decode", content)
+		}
+	}
+}
+
+func TestJsonPatchDiff(t *testing.T) {
+	from, _ := decodeJsonDoc([]byte(`{"a":1,"b":{"c":[1,2],"d":"x"},"e":[1],"gone":true}`))
+	to, _ := decodeJsonDoc([]byte(`{"a":2,"b":{"c":[1,3],"d":"x"},"e":[1,2],"new":null}`))
+
+	patch := jsonPatchDiff(from, to)
+
+	content, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatalf("Failed to encode patch: %v", err)
+	}
+
+	var decoded JsonPatch
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("Failed to decode patch %s: %v", content, err)
+	}
+
+	result, err := jsonPatchApply(deepCopyJsonDoc(from), decoded)
+	if err != nil {
+		t.Fatalf("Failed to apply diff %s: %v", content, err)
+	}
+
+	if !jsonEqual(result, to) {
+		t.Errorf("Expected diff %s to produce %v, got %v", content, to, result)
+	}
+
+	if patch := jsonPatchDiff(to, to); len(patch) != 0 {
+		t.Errorf("Expected no operations between equal documents, got %v", patch)
+	}
+}
+
+func TestJsonEqual(t *testing.T) {
+	equal := [][2]string{
+		{`1`, `1.0`},
+		{`1e2`, `100`},
+		{`{"a":[1,"x",null]}`, `{"a":[1.0,"x",null]}`},
+	}
+
+	different := [][2]string{
+		{`1234567890123456789`, `1234567890123456788`},
+		{`1`, `"1"`},
+		{`{"a":1}`, `{"a":1,"b":null}`},
+		{`[1,2]`, `[2,1]`},
+	}
+
+	for _, pair := range equal {
+		a, _ := decodeJsonDoc([]byte(pair[0]))
+		b, _ := decodeJsonDoc([]byte(pair[1]))
+		if !jsonEqual(a, b) {
+			t.Errorf("Expected %s to equal %s", pair[0], pair[1])
+		}
+	}
+
+	for _, pair := range different {
+		a, _ := decodeJsonDoc([]byte(pair[0]))
+		b, _ := decodeJsonDoc([]byte(pair[1]))
+		if jsonEqual(a, b) {
+			t.Errorf("Expected %s not to equal %s", pair[0], pair[1])
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package ktnuitygo
 
 import (
+	"errors"
 	"math"
 	"reflect"
+	"strings"
 	"unsafe"
 
 	"github.com/emirpasic/gods/sets/hashset"
@@ -93,55 +95,270 @@ func LastOrDefault[T any](slice []T, or T) T {
 
 type ErrorConsumerFn func(error)
 
-func InitDefault[T any]() T {
+type InitOption func(*initConfig)
+
+type initConfig struct {
+	force    bool
+	pointers bool
+	onError  ErrorConsumerFn
+	phase    initPhase
+}
+
+// Also allocates nil pointers to structs in exported fields, unless the struct
+// type is already being initialized further up, which would never end.
+func InitWithPointers() InitOption {
+	return func(config *initConfig) {
+		config.pointers = true
+	}
+}
+
+// Receives errors found while initializing, such as a DefaultTagError.
+func InitWithErrorConsumer(fn ErrorConsumerFn) InitOption {
+	return func(config *initConfig) {
+		config.onError = fn
+	}
+}
+
+func InitDefault[T any](options ...InitOption) T {
 	var zero T
-	verify(&zero, false)
+	verify(&zero, false, options...)
 	return zero
 }
 
-func ForceInitDefault[T any]() T {
+func ForceInitDefault[T any](options ...InitOption) T {
 	var zero T
-	verify(&zero, true)
+	verify(&zero, true, options...)
 	return zero
 }
 
-func verify[T any](inst *T, force bool) {
-	v := reflect.ValueOf(inst).Elem()
+// Replaces nil maps and slices throughout the value graph of inst and fills zero
+// fields from their `default:"..."` tags. With force, unexported fields of types
+// from the same package as T are initialized too.
+func verify[T any](inst *T, force bool, options ...InitOption) error {
+	config := initConfig{force: force}
+	for _, option := range options {
+		option(&config)
+	}
 
-	if v.Kind() != reflect.Struct {
-		return
+	v := reflect.ValueOf(inst).Elem()
+	walker := &initWalker{
+		config:   config,
+		pkgPath:  v.Type().PkgPath(),
+		visited:  make(map[uintptr]bool),
+		path:     make(map[reflect.Type]int),
+		defaults: make(map[defaultKey]defaultValue),
 	}
 
-	for i := range v.NumField() {
-		field := v.Field(i)
-		if !force && !field.CanSet() {
-			continue
+	walker.walk(v)
+
+	if config.onError != nil {
+		for _, err := range walker.errs {
+			config.onError(err)
 		}
+	}
+
+	return errors.Join(walker.errs...)
+}
+
+type initWalker struct {
+	config   initConfig
+	pkgPath  string
+	visited  map[uintptr]bool
+	// Struct types currently being walked, guards pointer allocation.
+	path     map[reflect.Type]int
+	defaults map[defaultKey]defaultValue
+	errs     []error
+}
 
-		switch field.Kind() {
-		case reflect.Map:
-			if field.IsNil() {
-				setField(field, reflect.MakeMap(field.Type()), force)
+// v must be settable.
+func (w *initWalker) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		w.path[v.Type()]++
+		defer func() {
+			w.path[v.Type()]--
+		}()
+
+		for i := range v.NumField() {
+			field := v.Field(i)
+			info := v.Type().Field(i)
+
+			if !info.IsExported() {
+				if !w.config.force || v.Type().PkgPath() != w.pkgPath {
+					continue
+				}
+
+				field = unsafeField(field)
 			}
-		case reflect.Slice:
-			if field.IsNil() {
-				setField(field, reflect.MakeSlice(field.Type(), 0, 8), force)
+
+			w.applyDefault(v.Type(), i, field)
+
+			if w.config.pointers && info.IsExported() && field.Kind() == reflect.Pointer && field.IsNil() {
+				elem := field.Type().Elem()
+				if elem.Kind() == reflect.Struct && w.path[elem] == 0 {
+					field.Set(reflect.New(elem))
+				}
 			}
+
+			w.walk(field)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			if w.config.phase != initBeforeDecode {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+
+			return
+		}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			value := addressable(iter.Value())
+			w.walk(value)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			if w.config.phase != initBeforeDecode {
+				v.Set(reflect.MakeSlice(v.Type(), 0, 8))
+			}
+
+			return
+		}
+
+		for i := range v.Len() {
+			w.walk(v.Index(i))
+		}
+	case reflect.Array:
+		for i := range v.Len() {
+			w.walk(v.Index(i))
+		}
+	case reflect.Pointer:
+		if v.IsNil() || w.visited[v.Pointer()] {
+			return
+		}
+
+		w.visited[v.Pointer()] = true
+		w.walk(v.Elem())
+	case reflect.Interface:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Pointer {
+			w.walk(addressable(v.Elem()))
 		}
 	}
 }
 
-func setField(v, x reflect.Value, force bool) {
-	if force {
-		forceSet(v, x)
-	} else {
-		v.Set(x)
+// Copies the whole value graph behind src, including unexported fields. Shared
+// and cyclic pointers are preserved, so pointers back to src point to the
+// returned copy. Funcs and channels are copied by reference, as are opaque
+// standard library structs like time.Time, see opaqueStruct.
+func deepCopy[T any](src *T) *T {
+	dst := new(T)
+	seen := map[uintptr]reflect.Value{
+		reflect.ValueOf(src).Pointer(): reflect.ValueOf(dst),
+	}
+
+	copyValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), seen)
+	return dst
+}
+
+// Standard library structs without exported fields are copied by value, as long
+// as their state is plain values and pointers. Cloning those pointers would break
+// identities they rely on, such as time.Local behind time.Time. Ones holding
+// slices or maps, such as big.Int, are still copied deeply.
+func opaqueStruct(t reflect.Type) bool {
+	path := t.PkgPath()
+	if path == "" || path == "main" || strings.Contains(strings.Split(path, "/")[0], ".") {
+		return false
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.IsExported() {
+			return false
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Interface, reflect.Struct, reflect.Array:
+			return false
+		}
 	}
+
+	return true
 }
 
-// Shouldn't be used ever, our use case is for DataTank specifically, and it might use package private values that still need to be set.
-func forceSet(v, x reflect.Value) {
-	reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).
-		Elem().
-		Set(x)
+// Both dst and src must be addressable.
+func copyValue(dst, src reflect.Value, seen map[uintptr]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+
+		if existing, ok := seen[src.Pointer()]; ok {
+			dst.Set(existing)
+			return
+		}
+
+		clone := reflect.New(src.Type().Elem())
+		seen[src.Pointer()] = clone
+		copyValue(clone.Elem(), src.Elem(), seen)
+		dst.Set(clone)
+	case reflect.Struct:
+		if opaqueStruct(src.Type()) {
+			dst.Set(src)
+			return
+		}
+
+		for i := range src.NumField() {
+			copyValue(unsafeField(dst.Field(i)), unsafeField(src.Field(i)), seen)
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+
+		clone := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		for i := range src.Len() {
+			copyValue(clone.Index(i), src.Index(i), seen)
+		}
+		dst.Set(clone)
+	case reflect.Array:
+		for i := range src.Len() {
+			copyValue(dst.Index(i), src.Index(i), seen)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+
+		clone := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			copyValue(value, addressable(iter.Value()), seen)
+			clone.SetMapIndex(iter.Key(), value)
+		}
+		dst.Set(clone)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+
+		inner := src.Elem()
+		value := reflect.New(inner.Type()).Elem()
+		copyValue(value, addressable(inner), seen)
+		dst.Set(value)
+	default:
+		dst.Set(src)
+	}
+}
+
+func unsafeField(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+func addressable(v reflect.Value) reflect.Value {
+	result := reflect.New(v.Type()).Elem()
+	result.Set(v)
+	return result
 }
@@ -3,6 +3,7 @@ package ktnuitygo
 import (
 	"math"
 	"testing"
+	"time"
 )
 
 func TestGetDefault(t *testing.T) {
@@ -234,6 +235,174 @@ func TestInitDefaultWithNestedStructs(t *testing.T) {
 		t.Error("Expected Items slice to be initialized")
 	}
 
-	// Note: nested struct fields are not automatically initialized by verify
-	// This is expected behavior
+	if result.Inner.Data == nil {
+		t.Error("Expected Inner.Data slice to be initialized")
+	}
+}
+
+type initNode struct {
+	Name     string
+	Tags     []string
+	Next     *initNode
+	Children map[string]initNode
+	Items    []initNode
+	meta     map[string]int
+}
+
+func TestInitDefaultNestedCollections(t *testing.T) {
+	type Inner struct {
+		Tags []string
+	}
+
+	type Outer struct {
+		ByName map[string]Inner
+		List   []Inner
+		Fixed  [2]Inner
+		Ptr    *Inner
+	}
+
+	var value Outer
+	value.ByName = map[string]Inner{"a": {}}
+	value.List = []Inner{{}}
+	value.Ptr = &Inner{}
+	verify(&value, false)
+
+	if value.ByName["a"].Tags == nil {
+		t.Error("Expected map values to be initialized")
+	}
+
+	if value.List[0].Tags == nil {
+		t.Error("Expected slice elements to be initialized")
+	}
+
+	if value.Fixed[1].Tags == nil {
+		t.Error("Expected array elements to be initialized")
+	}
+
+	if value.Ptr.Tags == nil {
+		t.Error("Expected pointed-to struct to be initialized")
+	}
+}
+
+func TestInitDefaultCycle(t *testing.T) {
+	a := &initNode{Name: "a"}
+	b := &initNode{Name: "b", Next: a}
+	a.Next = b
+
+	verify(a, true)
+
+	if a.Tags == nil || b.Tags == nil {
+		t.Error("Expected both nodes of the cycle to be initialized")
+	}
+
+	if a.meta == nil || b.meta == nil {
+		t.Error("Expected unexported fields to be force-initialized")
+	}
+}
+
+func TestInitDefaultWithPointers(t *testing.T) {
+	type Inner struct {
+		Tags []string
+	}
+
+	type Outer struct {
+		Inner *Inner
+		Skip  *int
+	}
+
+	result := InitDefault[Outer]()
+	if result.Inner != nil {
+		t.Error("Expected Inner to stay nil without InitWithPointers")
+	}
+
+	result = InitDefault[Outer](InitWithPointers())
+	if result.Inner == nil {
+		t.Fatal("Expected Inner to be allocated")
+	}
+
+	if result.Inner.Tags == nil {
+		t.Error("Expected allocated Inner to be initialized")
+	}
+
+	if result.Skip != nil {
+		t.Error("Expected non-struct pointers to stay nil")
+	}
+
+	node := InitDefault[initNode](InitWithPointers())
+	if node.Next != nil {
+		t.Error("Expected recursive pointer to stay nil")
+	}
+
+	if node.Children == nil || node.Items == nil {
+		t.Error("Expected recursive node collections to be initialized")
+	}
+}
+
+type deepCopyNode struct {
+	Name     string
+	tags     []string
+	Children map[string]*deepCopyNode
+	Parent   *deepCopyNode
+	Extra    any
+}
+
+func TestDeepCopy(t *testing.T) {
+	root := &deepCopyNode{
+		Name:     "root",
+		tags:     []string{"a", "b"},
+		Children: map[string]*deepCopyNode{},
+		Extra:    []int{1, 2},
+	}
+	child := &deepCopyNode{Name: "child", Parent: root}
+	root.Children["child"] = child
+
+	clone := deepCopy(root)
+
+	if clone.Name != "root" || len(clone.tags) != 2 || clone.tags[1] != "b" {
+		t.Fatalf("Expected clone to match original, got %+v", clone)
+	}
+
+	clone.tags[0] = "changed"
+	if root.tags[0] != "a" {
+		t.Error("Expected unexported slice to be copied, not shared")
+	}
+
+	clonedChild := clone.Children["child"]
+	if clonedChild == child {
+		t.Error("Expected map values to be copied, not shared")
+	}
+
+	if clonedChild.Parent != clone {
+		t.Error("Expected cyclic pointer to point into the copy")
+	}
+
+	clone.Extra.([]int)[0] = 5
+	if root.Extra.([]int)[0] != 1 {
+		t.Error("Expected interface contents to be copied, not shared")
+	}
+}
+
+func TestDeepCopyTime(t *testing.T) {
+	type timed struct {
+		At    time.Time
+		UTC   time.Time
+		Since *time.Time
+	}
+
+	now := time.Now()
+	original := timed{At: now, UTC: now.UTC(), Since: &now}
+
+	clone := deepCopy(&original)
+
+	if clone.At != original.At || clone.At.Location() != time.Local {
+		t.Errorf("Expected time to keep its location, got %v", clone.At.Location())
+	}
+
+	if clone.UTC != original.UTC {
+		t.Error("Expected UTC time to compare equal")
+	}
+
+	if clone.Since == original.Since || *clone.Since != now {
+		t.Error("Expected time pointer to be copied, keeping its value")
+	}
 }